@@ -17,7 +17,7 @@ func main() {
 	// from Grafana to create different instances of SampleDatasource (per datasource
 	// ID). When datasource configuration changed Dispose method will be called and
 	// new datasource instance created using NewSampleDatasource factory.
-	if err := datasource.Manage("wago-wemsgrafanaplugin-datasource", plugin.NewDatasource, datasource.ManageOpts{}); err != nil {
+	if err := datasource.Manage("wago-wemsgrafanaplugin-datasource", plugin.NewDatasource, datasource.ManageOpts{AdmissionHandler: plugin.AdmissionHandler{}}); err != nil {
 		log.DefaultLogger.Error(err.Error())
 		os.Exit(1)
 	}
@@ -7,10 +7,15 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
 
+// DefaultClientSecretKey is the secure JSON field holding the client secret
+// unless ClientSecretKey names a different one.
+const DefaultClientSecretKey = "client_secret"
+
 type PluginSettings struct {
-	ClientID string                `json:"client_id"`
-	BaseURL  string                `json:"base_url"`
-	Secrets  *SecretPluginSettings `json:"-"`
+	ClientID        string                `json:"client_id"`
+	BaseURL         string                `json:"base_url"`
+	ClientSecretKey string                `json:"client_secret_key"`
+	Secrets         *SecretPluginSettings `json:"-"`
 }
 
 type SecretPluginSettings struct {
@@ -22,12 +27,15 @@ func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSetti
 	if err := json.Unmarshal(source.JSONData, &settings); err != nil {
 		return nil, fmt.Errorf("could not unmarshal PluginSettings json: %w", err)
 	}
-	settings.Secrets = loadSecretPluginSettings(source.DecryptedSecureJSONData)
+	if settings.ClientSecretKey == "" {
+		settings.ClientSecretKey = DefaultClientSecretKey
+	}
+	settings.Secrets = loadSecretPluginSettings(source.DecryptedSecureJSONData, settings.ClientSecretKey)
 	return &settings, nil
 }
 
-func loadSecretPluginSettings(source map[string]string) *SecretPluginSettings {
+func loadSecretPluginSettings(source map[string]string, clientSecretKey string) *SecretPluginSettings {
 	return &SecretPluginSettings{
-		ClientSecret: source["client_secret"],
+		ClientSecret: source[clientSecretKey],
 	}
 }
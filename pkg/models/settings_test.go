@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLoadPluginSettingsClientSecretKey(t *testing.T) {
+	secure := map[string]string{"client_secret": "default", "wems_secret": "custom"}
+
+	settings, err := LoadPluginSettings(backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id"}`),
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Secrets.ClientSecret != "default" {
+		t.Errorf("expected default secret, got %q", settings.Secrets.ClientSecret)
+	}
+
+	settings, err = LoadPluginSettings(backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id","client_secret_key":"wems_secret"}`),
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Secrets.ClientSecret != "custom" {
+		t.Errorf("expected custom secret, got %q", settings.Secrets.ClientSecret)
+	}
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// QueryTypeAnnotations is the query type Grafana sends for annotation queries.
+const QueryTypeAnnotations = "annotations"
+
+// wemsEvent is an alarm or event as returned by the WEMS events endpoint.
+// TimeEnd is zero for point events.
+type wemsEvent struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags"`
+}
+
+// annotationFrame fetches the events of the endpoint in qm over the range of
+// query, optionally limited to one appliance, and returns them in the
+// annotation frame shape: time, timeEnd, text and tags.
+func (d *Datasource) annotationFrame(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) (*data.Frame, error) {
+	params := url.Values{
+		"from": {strconv.FormatInt(query.TimeRange.From.Unix(), 10)},
+		"to":   {strconv.FormatInt(query.TimeRange.To.Unix(), 10)},
+	}
+	if qm.ApplianceID != "" {
+		params.Set("applianceId", qm.ApplianceID)
+	}
+	path := fmt.Sprintf("/v1/endpoint/%s/events?%s", url.PathEscape(qm.EndpointID), params.Encode())
+	client := d.httpClient(20 * time.Second)
+	resp, err := d.doWithFailover(client, func(baseURL string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+d.bearer(ctx))
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(bodyBytes), RequestID: requestIDOf(resp)}
+	}
+
+	var events []wemsEvent
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, fmt.Errorf("failed to decode WEMS events: %w", err)
+	}
+
+	times := make([]time.Time, 0, len(events))
+	ends := make([]*time.Time, 0, len(events))
+	texts := make([]string, 0, len(events))
+	tags := make([]json.RawMessage, 0, len(events))
+	for _, e := range events {
+		times = append(times, time.Unix(e.Time, 0).UTC())
+		if e.TimeEnd != 0 {
+			end := time.Unix(e.TimeEnd, 0).UTC()
+			ends = append(ends, &end)
+		} else {
+			ends = append(ends, nil)
+		}
+		texts = append(texts, e.Text)
+		if e.Tags == nil {
+			e.Tags = []string{}
+		}
+		tagJSON, _ := json.Marshal(e.Tags)
+		tags = append(tags, tagJSON)
+	}
+	return data.NewFrame("annotations",
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, ends),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	), nil
+}
+
+// isAnnotationQuery reports whether query is an annotation query.
+func isAnnotationQuery(query backend.DataQuery) bool {
+	return strings.EqualFold(query.QueryType, QueryTypeAnnotations)
+}
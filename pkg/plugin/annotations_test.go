@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAnnotationQuery(t *testing.T) {
+	var gotPath string
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.Query()
+		_, _ = w.Write([]byte(`[
+			{"time":1700000100,"timeEnd":1700000400,"text":"Overtemperature","tags":["alarm","critical"]},
+			{"time":1700000500,"text":"Restart"}
+		]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "Anno",
+		QueryType: QueryTypeAnnotations,
+		JSON:      []byte(`{"endpoint_id":"ep","appliance_id":"app"}`),
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)},
+	})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if gotPath != "/v1/endpoint/ep/events" || gotQuery.Get("from") != "1700000000" || gotQuery.Get("to") != "1700003600" || gotQuery.Get("applianceId") != "app" {
+		t.Errorf("unexpected request %s?%s", gotPath, gotQuery.Encode())
+	}
+
+	frame := res.Frames[0]
+	if n, _ := frame.RowLen(); n != 2 {
+		t.Fatalf("expected 2 events, got %d", n)
+	}
+	for i, name := range []string{"time", "timeEnd", "text", "tags"} {
+		if frame.Fields[i].Name != name {
+			t.Fatalf("expected field %d to be %s, got %s", i, name, frame.Fields[i].Name)
+		}
+	}
+	if got := frame.Fields[0].At(0).(time.Time); got.Unix() != 1700000100 {
+		t.Errorf("unexpected start %v", got)
+	}
+	if got := frame.Fields[1].At(0).(*time.Time); got == nil || got.Unix() != 1700000400 {
+		t.Errorf("unexpected end %v", got)
+	}
+	if got := frame.Fields[1].At(1).(*time.Time); got != nil {
+		t.Errorf("expected no end for point event, got %v", got)
+	}
+	if got := frame.Fields[2].At(0).(string); got != "Overtemperature" {
+		t.Errorf("unexpected text %q", got)
+	}
+	var tags []string
+	if err := json.Unmarshal(frame.Fields[3].At(0).(json.RawMessage), &tags); err != nil || len(tags) != 2 || tags[1] != "critical" {
+		t.Errorf("unexpected tags %v (%v)", tags, err)
+	}
+}
+
+func TestAnnotationQueryEscapesApplianceID(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "Anno",
+		QueryType: QueryTypeAnnotations,
+		JSON:      []byte(`{"endpoint_id":"ep","appliance_id":"app 1&from=0#x"}`),
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)},
+	})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if gotQuery.Get("applianceId") != "app 1&from=0#x" || len(gotQuery["from"]) != 1 || gotQuery.Get("from") != "1700000000" {
+		t.Errorf("expected the appliance ID as a single parameter, got %v", gotQuery)
+	}
+}
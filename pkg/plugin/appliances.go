@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// applianceConfigCacheTTL is how long appliance-config reuses a fetched
+// endpoint description.
+const applianceConfigCacheTTL = 30 * time.Second
+
+// modelCacheTTL is how long resolved appliance model names are reused.
+const modelCacheTTL = 10 * time.Minute
+
+// applianceModelConcurrency is the default cap on the number of model lookups
+// appliance-list runs at the same time.
+const applianceModelConcurrency = 8
+
+func (d *Datasource) modelLookupConcurrency() int {
+	if d.settings.ModelLookupConcurrency > 0 {
+		return d.settings.ModelLookupConcurrency
+	}
+	return applianceModelConcurrency
+}
+
+// endpointDescription is the subset of the WEMS endpoint description used to
+// list appliances. Appliances are normally grouped by process; some
+// descriptions list them at the top level instead.
+type endpointDescription struct {
+	Processes  []process   `json:"processes"`
+	Appliances []appliance `json:"appliances"`
+}
+
+// errUnknownDescriptionShape is returned for endpoint descriptions listing
+// neither processes nor appliances.
+var errUnknownDescriptionShape = errors.New("endpoint description contains neither processes nor appliances")
+
+// applianceItems returns the appliances of desc with the name of their
+// process, if any. Descriptions with neither shape are logged and rejected
+// so they don't pass for an endpoint without appliances.
+func (desc endpointDescription) applianceItems() ([]processAppliance, error) {
+	if desc.Processes == nil && desc.Appliances == nil {
+		log.DefaultLogger.Warn("Unexpected endpoint description shape", "error", errUnknownDescriptionShape)
+		return nil, errUnknownDescriptionShape
+	}
+	var items []processAppliance
+	for _, proc := range desc.Processes {
+		for _, app := range proc.Appliances {
+			items = append(items, processAppliance{appliance: app, process: proc.Name})
+		}
+	}
+	for _, app := range desc.Appliances {
+		items = append(items, processAppliance{appliance: app})
+	}
+	return items, nil
+}
+
+type process struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Appliances []appliance `json:"appliances"`
+}
+
+type appliance struct {
+	ID                 string `json:"id"`
+	FriendlyName       string `json:"friendlyName"`
+	ApplianceReference int    `json:"applianceReference"`
+	// Configuration is only present when the description was requested
+	// with includeApplianceConfiguration=true.
+	Configuration json.RawMessage `json:"configuration,omitempty"`
+}
+
+// processAppliance is an appliance together with the name of its process.
+type processAppliance struct {
+	appliance
+	process string
+}
+
+// applianceOption builds the appliance-list entry for app, labelled with its
+// process and, if it can be resolved, its model name.
+func (d *Datasource) applianceOption(ctx context.Context, app processAppliance) (map[string]string, error) {
+	label := app.FriendlyName
+	if label == "" {
+		label = app.ID
+	}
+	if app.process != "" {
+		label = fmt.Sprintf("[%s] %s", app.process, label)
+	}
+	if app.ApplianceReference != 0 {
+		if modelLabel := d.applianceModelName(ctx, app.ApplianceReference); modelLabel != "" {
+			label = fmt.Sprintf("%s (%s)", label, modelLabel)
+		}
+	}
+	return map[string]string{"id": app.ID, "label": label}, nil
+}
+
+// applianceModelName looks up the friendly name of an appliance model,
+// caching resolved names for modelCacheTTL. Lookup failures are not fatal and
+// yield an empty name.
+func (d *Datasource) applianceModelName(ctx context.Context, ref int) string {
+	key := strconv.Itoa(ref)
+	if name, ok := d.models.Get(key); ok {
+		return name
+	}
+	name := d.fetchApplianceModelName(ctx, ref)
+	if name != "" {
+		d.models.Set(key, name, modelCacheTTL)
+	}
+	return name
+}
+
+func (d *Datasource) fetchApplianceModelName(ctx context.Context, ref int) string {
+	modelUrl := fmt.Sprintf("%s/v1/component/appliance/%d", d.currentBaseURL(), ref)
+	reqModel, err := http.NewRequestWithContext(ctx, "GET", modelUrl, nil)
+	if err != nil {
+		return ""
+	}
+	reqModel.Header.Set("Authorization", "Bearer "+d.bearer(ctx))
+	reqModel.Header.Set("Accept", "application/json")
+	client := d.httpClient(10 * time.Second)
+	respModel, err := d.send(client, reqModel)
+	if err != nil {
+		return ""
+	}
+	defer respModel.Body.Close()
+	if respModel.StatusCode != 200 {
+		return ""
+	}
+	var model struct {
+		FriendlyName string `json:"friendlyName"`
+	}
+	if err := json.NewDecoder(respModel.Body).Decode(&model); err != nil {
+		return ""
+	}
+	return model.FriendlyName
+}
+
+// collectAppliances runs worker for every item with at most limit running at
+// once and returns the results in item order. A worker that fails or panics
+// cancels the remaining ones and its error is returned.
+func collectAppliances(ctx context.Context, items []processAppliance, limit int, worker func(context.Context, processAppliance) (map[string]string, error)) ([]map[string]string, error) {
+	result := make([]map[string]string, len(items))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(limit)
+	for i, item := range items {
+		g.Go(func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("appliance %s: worker panicked: %v", item.ID, r)
+				}
+			}()
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			entry, err := worker(gctx, item)
+			if err != nil {
+				return fmt.Errorf("appliance %s: %w", item.ID, err)
+			}
+			result[i] = entry
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// applianceConfig is the response of the appliance-config resource.
+type applianceConfig struct {
+	ID                 string          `json:"id"`
+	FriendlyName       string          `json:"friendlyName"`
+	Process            string          `json:"process"`
+	ApplianceReference int             `json:"applianceReference"`
+	Configuration      json.RawMessage `json:"configuration"`
+}
+
+// applianceConfiguration serves the appliance-config resource, returning the
+// configuration of one appliance from the endpoint description.
+func (d *Datasource) applianceConfiguration(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	endpointId := ""
+	applianceId := ""
+	draft := "false"
+	if req.URL != "" {
+		if parsedUrl, err := url.Parse(req.URL); err == nil {
+			endpointId = parsedUrl.Query().Get("endpointId")
+			applianceId = parsedUrl.Query().Get("applianceId")
+			if v := parsedUrl.Query().Get("draft"); v != "" {
+				draft = v
+			}
+		}
+	}
+	if endpointId == "" || applianceId == "" {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Missing endpointId or applianceId parameter"),
+		})
+	}
+	if _, err := strconv.ParseBool(draft); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Invalid draft parameter"),
+		})
+	}
+
+	cacheKey := endpointId + "|" + draft
+	body, ok := d.configDescriptions.Get(cacheKey)
+	if !ok {
+		var errResp *backend.CallResourceResponse
+		body, errResp = d.getResourceBody(ctx, fmt.Sprintf("%s/v1/endpoint/%s/description?includeApplianceConfiguration=true&draft=%s", d.currentBaseURL(), endpointId, draft))
+		if errResp != nil {
+			return sender.Send(errResp)
+		}
+		d.configDescriptions.Set(cacheKey, body, applianceConfigCacheTTL)
+	}
+
+	var desc endpointDescription
+	if err := json.Unmarshal(body, &desc); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse appliances: " + err.Error()),
+		})
+	}
+	items, err := desc.applianceItems()
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte("Failed to parse appliances: " + err.Error()),
+		})
+	}
+	for _, app := range items {
+		if app.ID != applianceId {
+			continue
+		}
+		config := app.Configuration
+		if len(config) == 0 {
+			config = json.RawMessage("null")
+		}
+		return sendJSON(sender, applianceConfig{
+			ID:                 app.ID,
+			FriendlyName:       app.FriendlyName,
+			Process:            app.process,
+			ApplianceReference: app.ApplianceReference,
+			Configuration:      config,
+		})
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status: http.StatusNotFound,
+		Body:   []byte("Appliance not found"),
+	})
+}
@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCollectAppliancesOrder(t *testing.T) {
+	items := []processAppliance{
+		{appliance: appliance{ID: "a"}},
+		{appliance: appliance{ID: "b"}},
+		{appliance: appliance{ID: "c"}},
+	}
+	result, err := collectAppliances(context.Background(), items, 2, func(_ context.Context, app processAppliance) (map[string]string, error) {
+		if app.ID == "a" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return map[string]string{"id": app.ID}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if result[i]["id"] != want {
+			t.Errorf("position %d: expected %s, got %s", i, want, result[i]["id"])
+		}
+	}
+}
+
+func TestCollectAppliancesWorkerPanic(t *testing.T) {
+	items := []processAppliance{
+		{appliance: appliance{ID: "ok"}},
+		{appliance: appliance{ID: "boom"}},
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := collectAppliances(context.Background(), items, 2, func(_ context.Context, app processAppliance) (map[string]string, error) {
+			if app.ID == "boom" {
+				panic("unexpected payload")
+			}
+			return map[string]string{"id": app.ID}, nil
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error from the panicking worker")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("collectAppliances hung after a worker panic")
+	}
+}
+
+func TestCollectAppliancesWorkerError(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	_, err := collectAppliances(context.Background(), []processAppliance{{appliance: appliance{ID: "x"}}}, 1, func(context.Context, processAppliance) (map[string]string, error) {
+		return nil, errLookup
+	})
+	if !errors.Is(err, errLookup) {
+		t.Fatalf("expected wrapped lookup error, got %v", err)
+	}
+}
+
+func TestApplianceConfigResource(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("includeApplianceConfiguration") != "true" {
+			t.Errorf("expected configuration to be requested, got %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"processes":[{"id":"p1","name":"Heating","appliances":[
+			{"id":"app1","friendlyName":"Boiler","applianceReference":42,"configuration":{"ratedPower":12000,"phases":3}}
+		]}]}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-config", URL: "appliance-config?endpointId=ep&applianceId=app1"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var got applianceConfig
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "app1" || got.FriendlyName != "Boiler" || got.Process != "Heating" || got.ApplianceReference != 42 {
+		t.Errorf("unexpected appliance %+v", got)
+	}
+	var config struct {
+		RatedPower int `json:"ratedPower"`
+		Phases     int `json:"phases"`
+	}
+	if err := json.Unmarshal(got.Configuration, &config); err != nil || config.RatedPower != 12000 || config.Phases != 3 {
+		t.Errorf("unexpected configuration %s", got.Configuration)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-config", URL: "appliance-config?endpointId=ep&applianceId=missing"})
+	if res.Status != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown appliance, got %d", res.Status)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the description to be cached, got %d requests", calls.Load())
+	}
+}
+
+func TestApplianceListModelLookupConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/endpoint/ep/description" {
+			_, _ = w.Write([]byte(`{"processes":[{"name":"p","appliances":[
+				{"id":"a1","applianceReference":1},{"id":"a2","applianceReference":2},{"id":"a3","applianceReference":3},
+				{"id":"a4","applianceReference":4},{"id":"a5","applianceReference":5},{"id":"a6","applianceReference":6}
+			]}]}`))
+			return
+		}
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"friendlyName":"Model"}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ModelLookupConcurrency = 2
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-list", URL: "appliance-list?endpointId=ep"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("expected a peak of 2 concurrent lookups, got %d", got)
+	}
+}
+
+func TestApplianceListDescriptionShapes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		want   []string
+	}{
+		{"processes", `{"processes":[{"name":"Heating","appliances":[{"id":"a1","friendlyName":"Boiler"}]}]}`, http.StatusOK, []string{"[Heating] Boiler"}},
+		{"top-level appliances", `{"appliances":[{"id":"a1","friendlyName":"Boiler"},{"id":"a2"}]}`, http.StatusOK, []string{"Boiler", "a2"}},
+		{"empty processes", `{"processes":[]}`, http.StatusOK, []string{}},
+		{"unknown shape", `{"devices":[{"id":"a1"}]}`, http.StatusBadGateway, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ds := newTestDatasource(seriesServer(t, tc.body).URL)
+			res := callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-list", URL: "appliance-list?endpointId=ep"})
+			if res.Status != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, res.Status, res.Body)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var got []map[string]string
+			if err := json.Unmarshal(res.Body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d appliances, got %v", len(tc.want), got)
+			}
+			for i, label := range tc.want {
+				if got[i]["label"] != label {
+					t.Errorf("appliance %d: expected label %q, got %q", i, label, got[i]["label"])
+				}
+			}
+		})
+	}
+}
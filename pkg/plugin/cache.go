@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a small concurrency-safe cache whose entries expire after the
+// TTL given when they are stored. The zero value is ready to use.
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// Get returns the value stored under key if it has not expired yet.
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key for ttl and drops entries that have expired.
+func (c *ttlCache[V]) Set(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = make(map[string]ttlEntry[V])
+	}
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(ttl)}
+}
@@ -0,0 +1,21 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	var c ttlCache[string]
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("empty cache must miss")
+	}
+	c.Set("a", "x", time.Hour)
+	c.Set("b", "y", -time.Second)
+	if v, ok := c.Get("a"); !ok || v != "x" {
+		t.Errorf("expected hit for a, got %q %v", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("expired entry must miss")
+	}
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Calendar buckets, see WEMSQueryModel.CalendarBucket.
+const (
+	// CalendarBucketDay buckets points by calendar day.
+	CalendarBucketDay = "day"
+	// CalendarBucketWeek buckets points by ISO week, starting on Monday.
+	CalendarBucketWeek = "week"
+	// CalendarBucketMonth buckets points by calendar month.
+	CalendarBucketMonth = "month"
+)
+
+// calendarAggregateInterval returns the largest aggregate interval requested
+// for calendar bucketed queries over tr. WEMS aggregates over fixed durations
+// aligned to UTC, so coarser intervals would straddle calendar boundaries:
+// hours are used, or half or quarter hours in zones whose offset is not a
+// whole number of hours, such as Asia/Kolkata. Finer data is re-bucketed
+// client-side.
+func calendarAggregateInterval(loc *time.Location, tr backend.TimeRange) time.Duration {
+	interval := time.Hour
+	for _, t := range []time.Time{tr.From, tr.To} {
+		_, offset := t.In(loc).Zone()
+		for offset%int(interval/time.Second) != 0 && interval > 15*time.Minute {
+			interval /= 2
+		}
+	}
+	return interval
+}
+
+// calendarInterval returns the largest whole number of seconds not above
+// requested that divides aligned, so that no aggregate straddles a boundary
+// aligned intervals respect.
+func calendarInterval(requested, aligned time.Duration) time.Duration {
+	step := min(requested, aligned) / time.Second
+	for step > 1 && aligned%(step*time.Second) != 0 {
+		step--
+	}
+	return max(step, 1) * time.Second
+}
+
+// calendarReducer returns the reducer combining the points of a calendar
+// bucket of qm: its BucketReducer if set, else the one matching the aggregate
+// function, so that hourly sums add up to daily totals. Aggregates without a
+// counterpart, such as median, and raw series are averaged.
+func calendarReducer(qm WEMSQueryModel, aggregate string) string {
+	if qm.BucketReducer != "" {
+		return qm.BucketReducer
+	}
+	switch aggregate {
+	case "sum", "count":
+		return BucketReducerSum
+	case "min":
+		return BucketReducerMin
+	case "max":
+		return BucketReducerMax
+	case "first":
+		return BucketReducerFirst
+	case "last":
+		return BucketReducerLast
+	default:
+		return BucketReducerMean
+	}
+}
+
+// validateCalendarBucket validates the calendar bucketing options of qm.
+func validateCalendarBucket(qm WEMSQueryModel) error {
+	switch qm.CalendarBucket {
+	case "", CalendarBucketDay, CalendarBucketWeek, CalendarBucketMonth:
+	default:
+		return fmt.Errorf("unsupported calendar_bucket %q", qm.CalendarBucket)
+	}
+	if qm.CalendarTimezone != "" {
+		if _, err := time.LoadLocation(qm.CalendarTimezone); err != nil {
+			return fmt.Errorf("unsupported calendar_timezone %q: %w", qm.CalendarTimezone, err)
+		}
+	}
+	if qm.CalendarBucket != "" && qm.ResampleStep != "" {
+		return fmt.Errorf("calendar_bucket cannot be combined with resample_step")
+	}
+	return nil
+}
+
+// calendarLocation returns the zone calendar buckets of qm are aligned to:
+// its CalendarTimezone, else the datasource timezone, else UTC.
+func (d *Datasource) calendarLocation(qm WEMSQueryModel) *time.Location {
+	for _, name := range []string{qm.CalendarTimezone, d.settings.Timezone} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// calendarStart returns the start of the calendar bucket containing t in loc.
+func calendarStart(t time.Time, bucket string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+	switch bucket {
+	case CalendarBucketWeek:
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case CalendarBucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+}
+
+// calendarPoints groups points into calendar buckets in loc, so that days and
+// months keep their local length across DST changes. The points of a bucket
+// are combined by reduceBucket with reducer and stamped with the bucket
+// start; empty buckets are left out. The input is not modified.
+func calendarPoints(points []TimeSeriesDataPoint, bucket string, loc *time.Location, reducer string, opts convertOptions) []TimeSeriesDataPoint {
+	sorted := slices.Clone(points)
+	slices.SortStableFunc(sorted, comparePointTimes)
+
+	var result []TimeSeriesDataPoint
+	for i := 0; i < len(sorted); {
+		start := calendarStart(time.Unix(sorted[i].Time, 0), bucket, loc).Unix()
+		j := i + 1
+		for j < len(sorted) && calendarStart(time.Unix(sorted[j].Time, 0), bucket, loc).Unix() == start {
+			j++
+		}
+		p := reduceBucket(sorted[i:j], reducer, opts)
+		result = append(result, TimeSeriesDataPoint{Time: start, Value: p.Value, Interpolated: p.Interpolated})
+		i = j
+	}
+	return result
+}
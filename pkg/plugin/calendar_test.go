@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCalendarPointsDayAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string) int64 {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm.Unix()
+	}
+	// 2023-03-26 has 23 hours in Berlin: it starts at 23:00 UTC the day
+	// before and ends at 22:00 UTC.
+	points := []TimeSeriesDataPoint{
+		{Time: at("2023-03-25T22:30:00Z"), Value: 1.0},
+		{Time: at("2023-03-25T23:30:00Z"), Value: 2.0},
+		{Time: at("2023-03-26T21:30:00Z"), Value: 3.0},
+		{Time: at("2023-03-26T22:30:00Z"), Value: 4.0},
+	}
+
+	got := calendarPoints(points, CalendarBucketDay, berlin, BucketReducerMax, convertOptions{})
+	want := []TimeSeriesDataPoint{
+		{Time: at("2023-03-24T23:00:00Z"), Value: 1.0},
+		{Time: at("2023-03-25T23:00:00Z"), Value: 3.0},
+		{Time: at("2023-03-26T22:00:00Z"), Value: 4.0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d buckets, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Time != want[i].Time || got[i].Value != want[i].Value {
+			t.Errorf("bucket %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCalendarPointsMonthAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string) int64 {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm.Unix()
+	}
+	// March starts in CET (UTC+1) and April in CEST (UTC+2).
+	points := []TimeSeriesDataPoint{
+		{Time: at("2023-02-28T23:30:00Z"), Value: 1.0},
+		{Time: at("2023-03-31T21:30:00Z"), Value: 3.0},
+		{Time: at("2023-03-31T22:30:00Z"), Value: 5.0},
+		{Time: at("2023-04-15T12:00:00Z"), Value: 7.0},
+	}
+
+	got := calendarPoints(points, CalendarBucketMonth, berlin, BucketReducerMean, convertOptions{})
+	want := []TimeSeriesDataPoint{
+		{Time: at("2023-02-28T23:00:00Z"), Value: 2.0},
+		{Time: at("2023-03-31T22:00:00Z"), Value: 6.0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d buckets, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Time != want[i].Time || got[i].Value != want[i].Value {
+			t.Errorf("bucket %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if start := calendarStart(time.Unix(at("2023-03-29T10:00:00Z"), 0), CalendarBucketWeek, berlin); start.Unix() != at("2023-03-26T22:00:00Z") {
+		t.Errorf("expected week to start on Monday 2023-03-27, got %v", start)
+	}
+}
+
+func TestQueryCalendarBucket(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700003000,"value":2}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","calendar_bucket":"day","calendar_timezone":"UTC","bucket_reducer":"max"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if n := frame.Fields[0].Len(); n != 1 {
+		t.Fatalf("expected one daily bucket, got %d", n)
+	}
+	if ts := frame.Fields[0].At(0).(time.Time); ts.Unix() != 1699920000 {
+		t.Errorf("expected bucket at midnight UTC, got %v", ts)
+	}
+	if v, _ := frame.Fields[1].FloatAt(0); v != 2 {
+		t.Errorf("expected max 2, got %v", v)
+	}
+
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp", CalendarBucket: CalendarBucketDay}
+	if path := ds.seriesPath(qm, backend.DataQuery{Interval: 6 * time.Hour}); !strings.Contains(path, "aggregateInterval=3600s") {
+		t.Errorf("expected hourly aggregation for calendar buckets, got %s", path)
+	}
+
+	for _, model := range []string{
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","calendar_bucket":"year"}`,
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","calendar_bucket":"day","calendar_timezone":"Mars/Olympus"}`,
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","calendar_bucket":"day","resample_step":"1h"}`,
+	} {
+		if res := runQuery(ds, model); res.Error == nil {
+			t.Errorf("expected error for %s", model)
+		}
+	}
+}
+
+func TestQueryCalendarBucketDailyTotals(t *testing.T) {
+	// Hourly sums of one UTC day.
+	srv := seriesServer(t, `[{"time":1699920000,"value":1.5},{"time":1699923600,"value":2},{"time":1699927200,"value":0.5}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","aggregate_function":"sum","calendar_bucket":"day"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if n := res.Frames[0].Rows(); n != 1 {
+		t.Fatalf("expected one daily bucket, got %d", n)
+	}
+	if v, _ := res.Frames[0].Fields[1].FloatAt(0); v != 4 {
+		t.Errorf("expected the daily total 4, got %v", v)
+	}
+
+	for aggregate, want := range map[string]string{"": BucketReducerMean, "count": BucketReducerSum, "max": BucketReducerMax, "median": BucketReducerMean} {
+		if got := calendarReducer(WEMSQueryModel{}, aggregate); got != want {
+			t.Errorf("aggregate %q: expected reducer %q, got %q", aggregate, want, got)
+		}
+	}
+	if got := calendarReducer(WEMSQueryModel{BucketReducer: BucketReducerLast}, "sum"); got != BucketReducerLast {
+		t.Errorf("expected bucket_reducer to win, got %q", got)
+	}
+}
+
+func TestCalendarAggregateInterval(t *testing.T) {
+	tr := backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700086400, 0)}
+	for zone, want := range map[string]time.Duration{
+		"UTC":              time.Hour,
+		"Europe/Berlin":    time.Hour,
+		"Asia/Kolkata":     30 * time.Minute,
+		"Asia/Kathmandu":   15 * time.Minute,
+		"Australia/Darwin": 30 * time.Minute,
+	} {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Skip(err)
+		}
+		if got := calendarAggregateInterval(loc, tr); got != want {
+			t.Errorf("%s: expected %v, got %v", zone, want, got)
+		}
+	}
+
+	ds := newTestDatasource("")
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp", CalendarBucket: CalendarBucketDay, CalendarTimezone: "Asia/Kolkata"}
+	if path := ds.seriesPath(qm, backend.DataQuery{TimeRange: tr, Interval: 6 * time.Hour}); !strings.Contains(path, "aggregateInterval=1800s") {
+		t.Errorf("expected half-hourly aggregation in Asia/Kolkata, got %s", path)
+	}
+	// Intervals not dividing the aligned one are reduced to a divisor of it.
+	for _, tc := range []struct {
+		zone     string
+		interval time.Duration
+		want     string
+	}{
+		{"Asia/Kolkata", 20 * time.Minute, "aggregateInterval=900s"},
+		{"UTC", 7 * time.Minute, "aggregateInterval=400s"},
+		{"UTC", 90 * time.Second, "aggregateInterval=90s"},
+	} {
+		qm.CalendarTimezone = tc.zone
+		if path := ds.seriesPath(qm, backend.DataQuery{TimeRange: tr, Interval: tc.interval}); !strings.Contains(path, tc.want) {
+			t.Errorf("%s %v: expected %s, got %s", tc.zone, tc.interval, tc.want, path)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package plugin
+
+import "fmt"
+
+// Clamp modes for values outside the range given by WEMSQueryModel.ClampMin
+// and ClampMax.
+const (
+	// ClampModeDrop replaces them with null.
+	ClampModeDrop = "drop"
+	// ClampModeClamp replaces them with the nearest bound.
+	ClampModeClamp = "clamp"
+)
+
+// validateClamp checks the clamping options of qm.
+func validateClamp(qm WEMSQueryModel) error {
+	switch qm.ClampMode {
+	case "", ClampModeDrop, ClampModeClamp:
+	default:
+		return fmt.Errorf("unsupported clamp_mode %q", qm.ClampMode)
+	}
+	if qm.ClampMin != nil && qm.ClampMax != nil && *qm.ClampMin > *qm.ClampMax {
+		return fmt.Errorf("invalid clamp range: clamp_min %v is above clamp_max %v", *qm.ClampMin, *qm.ClampMax)
+	}
+	return nil
+}
+
+// clampValues applies the clamping options of qm to values. In clamp mode
+// values are replaced in place; in drop mode the values to replace with null
+// are flagged in the returned slice, which is nil if there are none.
+func clampValues(values []float64, qm WEMSQueryModel) []bool {
+	if qm.ClampMin == nil && qm.ClampMax == nil {
+		return nil
+	}
+	var dropped []bool
+	for i, v := range values {
+		bound := v
+		if qm.ClampMin != nil && v < *qm.ClampMin {
+			bound = *qm.ClampMin
+		}
+		if qm.ClampMax != nil && v > *qm.ClampMax {
+			bound = *qm.ClampMax
+		}
+		switch {
+		case bound == v:
+		case qm.ClampMode == ClampModeClamp:
+			values[i] = bound
+		default:
+			if dropped == nil {
+				dropped = make([]bool, len(values))
+			}
+			dropped[i] = true
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestQueryClamp(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":-40},{"time":1700000060,"value":21.5},{"time":1700000120,"value":850},{"time":1700000180,"value":22}]`)
+	ds := newTestDatasource(srv.URL)
+
+	for _, tc := range []struct {
+		mode string
+		want []*float64
+	}{
+		{"", []*float64{nil, ptr(21.5), nil, ptr(22.0)}},
+		{ClampModeDrop, []*float64{nil, ptr(21.5), nil, ptr(22.0)}},
+		{ClampModeClamp, []*float64{ptr(-20.0), ptr(21.5), ptr(100.0), ptr(22.0)}},
+	} {
+		res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","clamp_min":-20,"clamp_max":100,"clamp_mode":"`+tc.mode+`"}`)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		field := res.Frames[0].Fields[1]
+		if field.Len() != len(tc.want) {
+			t.Fatalf("mode %q: expected %d values, got %d", tc.mode, len(tc.want), field.Len())
+		}
+		for i, want := range tc.want {
+			v, ok := field.ConcreteAt(i)
+			if ok != (want != nil) || (ok && v != *want) {
+				t.Errorf("mode %q: value %d: expected %v, got %v", tc.mode, i, want, v)
+			}
+		}
+	}
+
+	// Only a lower bound; values in range keep a non-nullable field.
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","clamp_min":-50}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if field := res.Frames[0].Fields[1]; field.Type().Nullable() {
+		t.Errorf("expected a plain field when nothing is dropped, got %s", field.Type())
+	}
+
+	for _, model := range []string{
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","clamp_min":10,"clamp_max":0}`,
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","clamp_max":10,"clamp_mode":"wrap"}`,
+	} {
+		if res := runQuery(ds, model); res.Error == nil {
+			t.Errorf("expected error for %s", model)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Behaviours for series that mix value types, see DatasourceSettings.OnMixedType.
+const (
+	// MixedTypeCoerce converts every value to a number as far as possible.
+	MixedTypeCoerce = "coerce"
+	// MixedTypeError fails the query.
+	MixedTypeError = "error"
+	// MixedTypeString returns the values as strings.
+	MixedTypeString = "string"
+)
+
+// Behaviours for NaN and infinite values after conversion, see
+// DatasourceSettings.OnNonFinite.
+const (
+	// NonFiniteNull replaces them with null.
+	NonFiniteNull = "null"
+	// NonFiniteError fails the query.
+	NonFiniteError = "error"
+)
+
+func isNonFinite(v float64) bool {
+	return math.IsNaN(v) || math.IsInf(v, 0)
+}
+
+// finiteValues returns values as nullable values, with NaN and infinite
+// values replaced by null.
+func finiteValues(values []float64) []*float64 {
+	nullable := make([]*float64, len(values))
+	for i := range values {
+		if !isNonFinite(values[i]) {
+			nullable[i] = &values[i]
+		}
+	}
+	return nullable
+}
+
+// valueKind classifies a decoded WEMS value as "number", "bool", "string" or
+// "other". Nulls are reported as "".
+func valueKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case float64, int, int64:
+		return "number"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	default:
+		return "other"
+	}
+}
+
+// valueKinds returns the sorted set of value kinds occurring in points,
+// ignoring nulls.
+func valueKinds(points []TimeSeriesDataPoint) []string {
+	var kinds []string
+	for _, p := range points {
+		if k := valueKind(p.Value); k != "" && !slices.Contains(kinds, k) {
+			kinds = append(kinds, k)
+		}
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// maxExactInt is the largest magnitude up to which every integer is exactly
+// representable as a float64.
+const maxExactInt = 1 << 53
+
+// needsInt64 reports whether every value of points is an int64 and at least
+// one of them exceeds maxExactInt, so that converting them to float64 would
+// round them.
+func needsInt64(points []TimeSeriesDataPoint) bool {
+	large := false
+	for _, p := range points {
+		v, ok := p.Value.(int64)
+		if !ok {
+			return false
+		}
+		large = large || v > maxExactInt || v < -maxExactInt
+	}
+	return large
+}
+
+// intPoints splits WEMS points whose values are all int64 into frame
+// columns, see needsInt64. Times are in UTC.
+func intPoints(points []TimeSeriesDataPoint) ([]time.Time, []int64) {
+	times := make([]time.Time, 0, len(points))
+	values := make([]int64, 0, len(points))
+	for _, p := range points {
+		times = append(times, time.Unix(p.Time, 0).UTC())
+		values = append(values, p.Value.(int64))
+	}
+	return times, values
+}
+
+// stringPoints splits WEMS points into frame columns, formatting each value
+// as a string. Times are in UTC.
+func stringPoints(points []TimeSeriesDataPoint) ([]time.Time, []string) {
+	times := make([]time.Time, 0, len(points))
+	values := make([]string, 0, len(points))
+	for _, p := range points {
+		times = append(times, time.Unix(p.Time, 0).UTC())
+		values = append(values, fmt.Sprint(p.Value))
+	}
+	return times, values
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryOnMixedType(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.5},{"time":1700000060,"value":"on"},{"time":1700000120,"value":"2"}]`)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	ds := newTestDatasource(srv.URL)
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Fields[1].At(1).(float64); got != 0 {
+		t.Errorf("coerce: expected unparsable value to become 0, got %v", got)
+	}
+
+	ds.settings.OnMixedType = MixedTypeError
+	res = runQuery(ds, model)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "number, string") {
+		t.Errorf("error: expected mixed type error, got %v", res.Error)
+	}
+
+	ds.settings.OnMixedType = MixedTypeString
+	res = runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	for i, want := range []string{"1.5", "on", "2"} {
+		if got := field.At(i).(string); got != want {
+			t.Errorf("string: point %d: expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestQueryOnMixedTypeHomogeneousSeries(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.5},{"time":1700000060,"value":null},{"time":1700000120,"value":2}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.OnMixedType = MixedTypeError
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatalf("nulls must not count as a mixed type: %v", res.Error)
+	}
+}
+
+func TestQueryRawMode(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":"door open"},{"time":1700000060,"value":1.5},{"time":1700000120,"value":true},{"time":1700000180,"value":{"code":7}}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.OnMixedType = MixedTypeError
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","mode":"raw"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	for i, want := range []string{"door open", "1.5", "true", "map[code:7]"} {
+		if got := field.At(i).(string); got != want {
+			t.Errorf("point %d: expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestQueryWarnOnAllZeroCoerced(t *testing.T) {
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+	for _, tc := range []struct {
+		body   string
+		notice bool
+	}{
+		{`[{"time":1700000000,"value":"on"},{"time":1700000060,"value":null},{"time":1700000120,"value":"off"}]`, true},
+		{`[{"time":1700000000,"value":"on"},{"time":1700000060,"value":"0"}]`, false},
+		{`[{"time":1700000000,"value":null}]`, false},
+	} {
+		ds := newTestDatasource(seriesServer(t, tc.body).URL)
+		ds.settings.WarnOnAllZeroCoerced = true
+		res := runQuery(ds, model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		meta := res.Frames[0].Meta
+		got := meta != nil && len(meta.Notices) == 1 && strings.Contains(meta.Notices[0].Text, "converted")
+		if got != tc.notice {
+			t.Errorf("%s: expected notice %v, got %+v", tc.body, tc.notice, meta)
+		}
+
+		ds.settings.WarnOnAllZeroCoerced = false
+		if res := runQuery(ds, model); res.Frames[0].Meta != nil {
+			t.Errorf("%s: expected no notice when disabled, got %+v", tc.body, res.Frames[0].Meta)
+		}
+	}
+}
+
+func TestQueryNonFiniteValues(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.5},{"time":1700000060,"value":"NaN"},{"time":1700000120,"value":"+Inf"},{"time":1700000180,"value":"-Infinity"}]`)
+	ds := newTestDatasource(srv.URL)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	if field.Len() != 4 {
+		t.Fatalf("expected 4 values, got %d", field.Len())
+	}
+	if v := field.At(0).(*float64); v == nil || *v != 1.5 {
+		t.Errorf("expected finite value to be kept, got %v", v)
+	}
+	for i := 1; i < 4; i++ {
+		if v := field.At(i).(*float64); v != nil {
+			t.Errorf("value %d: expected null, got %v", i, *v)
+		}
+	}
+	if _, err := json.Marshal(res.Frames[0]); err != nil {
+		t.Errorf("expected frame to encode, got %v", err)
+	}
+
+	ds.settings.OnNonFinite = NonFiniteError
+	if res := runQuery(ds, model); res.Error == nil || !strings.Contains(res.Error.Error(), "non-finite") {
+		t.Errorf("expected non-finite values to fail the query, got %v", res.Error)
+	}
+}
+
+func TestNonFinitePolicyAppliesToAllOutputs(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.5},{"time":1700000060,"value":"NaN"},{"time":1700000120,"value":"+Inf"},{"time":1700000180,"value":2.5}]`)
+	ds := newTestDatasource(srv.URL)
+	series := `"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"`
+	queries := map[string]string{
+		"summary":   `{` + series + `,"summary_stats":true}`,
+		"histogram": `{` + series + `,"histogram":true}`,
+		"ratio": `{"endpoint_id":"ep","appliance_id":"app",
+			"numerator":{"service_uri":"svc","data_point":"dp"},
+			"denominator":{"service_uri":"svc","data_point":"dp"}}`,
+	}
+	exportCSV := func() *backend.CallResourceResponse {
+		return callResource(t, ds, &backend.CallResourceRequest{
+			Path: "export-csv",
+			URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700003600",
+		})
+	}
+
+	for name, model := range queries {
+		if res := runQuery(ds, model); res.Error != nil {
+			t.Errorf("%s: %v", name, res.Error)
+		}
+	}
+	res := runQuery(ds, queries["summary"])
+	if sum := res.Frames[1].Fields[3].At(0).(*float64); sum == nil || *sum != 4 {
+		t.Errorf("expected the summary to skip non-finite values, got sum %v", sum)
+	}
+	res = runQuery(ds, queries["ratio"])
+	if n := res.Frames[0].Rows(); n != 2 {
+		t.Errorf("expected the ratio to skip non-finite values, got %d rows", n)
+	}
+	csv := exportCSV()
+	if csv.Status != http.StatusOK || strings.Contains(string(csv.Body), "NaN") || strings.Contains(string(csv.Body), "Inf") {
+		t.Errorf("expected empty cells for non-finite values, got %d:\n%s", csv.Status, csv.Body)
+	}
+
+	ds.settings.OnNonFinite = NonFiniteError
+	for name, model := range queries {
+		if res := runQuery(ds, model); res.Error == nil || !strings.Contains(res.Error.Error(), "non-finite") {
+			t.Errorf("%s: expected non-finite values to fail the query, got %v", name, res.Error)
+		}
+	}
+	if csv := exportCSV(); csv.Status == http.StatusOK || !strings.Contains(string(csv.Body), "non-finite") {
+		t.Errorf("expected non-finite values to fail the export, got %d: %s", csv.Status, csv.Body)
+	}
+}
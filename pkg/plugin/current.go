@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// currentFrame fetches the current value of the datapoint described by qm and
+// returns it as a single-point frame. If WEMS does not report a timestamp, the
+// time of the request is used.
+func (d *Datasource) currentFrame(ctx context.Context, qm WEMSQueryModel) (*data.Frame, error) {
+	path := fmt.Sprintf("/v1/endpoint/%s/values/%s/%s/%s", qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
+	client := d.httpClient(queryTimeout(ctx))
+	resp, err := d.doWithFailover(client, func(baseURL string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+d.bearer(ctx))
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(bodyBytes), RequestID: requestIDOf(resp)}
+	}
+
+	var point TimeSeriesDataPoint
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&point); err != nil {
+		return nil, fmt.Errorf("failed to decode WEMS response: %w", err)
+	}
+	point.Value = numberValue(point.Value)
+	if point.Time == 0 {
+		point.Time = time.Now().Unix()
+	}
+	return d.pointsFrame(qm, []TimeSeriesDataPoint{point})
+}
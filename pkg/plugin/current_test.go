@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueryCurrentMode(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"time":1700000000,"value":"230.5"}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","mode":"current"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if want := "/v1/endpoint/ep/values/app/svc/dp"; gotPath != want {
+		t.Errorf("expected path %s, got %s", want, gotPath)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 1 {
+		t.Fatalf("expected a single point, got %d", frame.Rows())
+	}
+	if got := frame.Fields[0].At(0).(time.Time); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("unexpected time %v", got)
+	}
+	if got := frame.Fields[1].At(0).(float64); got != 230.5 {
+		t.Errorf("unexpected value %v", got)
+	}
+}
+
+func TestQueryCurrentModeWithoutTimestamp(t *testing.T) {
+	srv := seriesServer(t, `{"value":true}`)
+	ds := newTestDatasource(srv.URL)
+
+	before := time.Now().Add(-time.Second)
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","mode":"current"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Fields[0].At(0).(time.Time); got.Before(before) {
+		t.Errorf("expected request time, got %v", got)
+	}
+	if got := res.Frames[0].Fields[1].At(0).(float64); got != 1 {
+		t.Errorf("unexpected value %v", got)
+	}
+}
@@ -0,0 +1,28 @@
+package plugin
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// maxSeriesPages caps the number of pages followed for one paginated series,
+// so a server handing out cursors endlessly cannot stall a query.
+const maxSeriesPages = 100
+
+// ErrTooManyPages is returned for paginated series with more than
+// maxSeriesPages pages.
+var ErrTooManyPages = errors.New("series has too many pages")
+
+// cursorPath returns the series path requesting the page at cursor, or path
+// itself for the first page.
+func cursorPath(path, cursor string) string {
+	if cursor == "" {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "cursor=" + url.QueryEscape(cursor)
+}
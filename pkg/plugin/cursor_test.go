@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQueryFollowsSeriesCursor(t *testing.T) {
+	var cursors []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("from") != "1700000000" {
+			t.Errorf("expected the range on every page, got %s", r.URL.RawQuery)
+		}
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		switch cursor {
+		case "":
+			_, _ = w.Write([]byte(`{"points":[{"time":1700000000,"value":1}],"nextCursor":"page 2"}`))
+		case "page 2":
+			_, _ = w.Write([]byte(`{"points":[{"time":1700000060,"value":2}],"nextCursor":"page3"}`))
+		default:
+			_, _ = w.Write([]byte(`{"points":[{"time":1700000120,"value":3}]}`))
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	values := res.Frames[0].Fields[1]
+	if values.Len() != 3 {
+		t.Fatalf("expected 3 points from 3 pages, got %d", values.Len())
+	}
+	for i := 0; i < 3; i++ {
+		if v, _ := values.FloatAt(i); v != float64(i+1) {
+			t.Errorf("point %d: expected %d, got %v", i, i+1, v)
+		}
+	}
+	if len(cursors) != 3 || cursors[1] != "page 2" || cursors[2] != "page3" {
+		t.Errorf("unexpected cursors %q", cursors)
+	}
+}
+
+func TestSeriesCursorGuards(t *testing.T) {
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cancelAfter atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == cancelAfter.Load() {
+			cancel()
+		}
+		_, _ = w.Write([]byte(`{"points":[{"time":1700000000,"value":1}],"nextCursor":"c` + strconv.Itoa(int(n)) + `"}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	_, err := ds.requestSeries(context.Background(), "/v1/endpoint/ep/series/app/svc/dp?from=1700000000")
+	if !errors.Is(err, ErrTooManyPages) {
+		t.Fatalf("expected ErrTooManyPages, got %v", err)
+	}
+	if got := calls.Load(); got != maxSeriesPages {
+		t.Errorf("expected %d page requests, got %d", maxSeriesPages, got)
+	}
+
+	calls.Store(0)
+	cancelAfter.Store(2)
+	if _, err := ds.requestSeries(ctx, "/v1/endpoint/ep/series/app/svc/dp?from=1700000000"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected pagination to stop once the context is done, got %d requests", got)
+	}
+}
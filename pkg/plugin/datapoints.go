@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// metadataLookupConcurrency is the default cap on the number of datapoint
+// metadata lookups datapoint-list runs at the same time.
+const metadataLookupConcurrency = 8
+
+func (d *Datasource) metadataLookupConcurrency() int {
+	if d.settings.MetadataLookupConcurrency > 0 {
+		return d.settings.MetadataLookupConcurrency
+	}
+	return metadataLookupConcurrency
+}
+
+// datapointMetadataFields are the datapoint attributes datapoint-list fills
+// in from per-datapoint lookups when the service listing lacks them.
+var datapointMetadataFields = []string{"type", "unit"}
+
+// datapointList serves the datapoint-list resource, returning the datapoints
+// of a service as listed by WEMS, enriched with the metadata of datapoints
+// listed without it.
+func (d *Datasource) datapointList(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	endpointId := ""
+	applianceId := ""
+	serviceUri := ""
+	if req.URL != "" {
+		if parsedUrl, err := url.Parse(req.URL); err == nil {
+			endpointId = parsedUrl.Query().Get("endpointId")
+			applianceId = parsedUrl.Query().Get("applianceId")
+			serviceUri = parsedUrl.Query().Get("serviceUri")
+		}
+	}
+	if endpointId == "" || applianceId == "" || serviceUri == "" {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Missing endpointId, applianceId, or serviceUri parameter"),
+		})
+	}
+	serviceURL := fmt.Sprintf("%s/v1/endpoint/%s/values/%s/%s", d.currentBaseURL(), endpointId, applianceId, serviceUri)
+	body, errResp := d.getResourceBody(ctx, serviceURL)
+	if errResp != nil {
+		return sender.Send(errResp)
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status: http.StatusOK,
+		Body:   d.enrichDatapoints(ctx, serviceURL, body),
+	})
+}
+
+// enrichDatapoints looks up the datapoints of the service listing body that
+// lack any of datapointMetadataFields, at most metadataLookupConcurrency at a
+// time, and merges the missing fields into the listing. Lookup failures are
+// not fatal and leave the datapoint unchanged; listings of an unexpected
+// shape are returned as is.
+func (d *Datasource) enrichDatapoints(ctx context.Context, serviceURL string, body []byte) []byte {
+	var listing map[string]json.RawMessage
+	var dataPoints map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &listing); err != nil || listing["dataPoints"] == nil {
+		return body
+	}
+	if err := json.Unmarshal(listing["dataPoints"], &dataPoints); err != nil {
+		return body
+	}
+
+	var names []string
+	for name, dp := range dataPoints {
+		if slices.ContainsFunc(datapointMetadataFields, func(field string) bool { return dp[field] == nil }) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return body
+	}
+	slices.Sort(names)
+
+	metadata := make([]map[string]json.RawMessage, len(names))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.metadataLookupConcurrency())
+	for i, name := range names {
+		g.Go(func() error {
+			metaBody, errResp := d.getResourceBody(gctx, serviceURL+"/"+url.PathEscape(name))
+			if errResp != nil {
+				log.DefaultLogger.Debug("Datapoint metadata lookup failed", "datapoint", name, "status", errResp.Status)
+				return nil
+			}
+			if err := json.Unmarshal(metaBody, &metadata[i]); err != nil {
+				log.DefaultLogger.Debug("Failed to parse datapoint metadata", "datapoint", name, "error", err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	for i, name := range names {
+		dp := dataPoints[name]
+		for _, field := range datapointMetadataFields {
+			if v, ok := metadata[i][field]; ok && dp[field] == nil {
+				if dp == nil {
+					dp = make(map[string]json.RawMessage)
+				}
+				dp[field] = v
+			}
+		}
+		dataPoints[name] = dp
+	}
+	enriched, err := json.Marshal(dataPoints)
+	if err != nil {
+		return body
+	}
+	listing["dataPoints"] = enriched
+	out, err := json.Marshal(listing)
+	if err != nil {
+		return body
+	}
+	return out
+}
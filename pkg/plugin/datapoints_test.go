@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDatapointListMetadata(t *testing.T) {
+	var active, peak, lookups atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/endpoint/ep/values/app/svc" {
+			listing := `{"dataPoints":{"known":{"value":1,"type":"Reading","unit":"W"}`
+			for i := 0; i < 6; i++ {
+				listing += `,"dp` + strconv.Itoa(i) + `":{"value":` + strconv.Itoa(i) + `}`
+			}
+			_, _ = w.Write([]byte(listing + `}}`))
+			return
+		}
+		lookups.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/v1/endpoint/ep/values/app/svc/dp5" {
+			http.Error(w, "gone", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"time":1700000000,"value":1,"type":"Reading","unit":"kWh"}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MetadataLookupConcurrency = 2
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "datapoint-list", URL: "datapoint-list?endpointId=ep&applianceId=app&serviceUri=svc"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var got struct {
+		DataPoints map[string]struct {
+			Value json.Number `json:"value"`
+			Type  string      `json:"type"`
+			Unit  string      `json:"unit"`
+		} `json:"dataPoints"`
+	}
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.DataPoints) != 7 {
+		t.Fatalf("expected 7 datapoints, got %s", res.Body)
+	}
+	if dp := got.DataPoints["known"]; dp.Unit != "W" || dp.Type != "Reading" {
+		t.Errorf("expected listed metadata to be kept, got %+v", dp)
+	}
+	for i := 0; i < 5; i++ {
+		name := "dp" + strconv.Itoa(i)
+		if dp := got.DataPoints[name]; dp.Unit != "kWh" || dp.Type != "Reading" || dp.Value.String() != strconv.Itoa(i) {
+			t.Errorf("%s: expected enriched datapoint, got %+v", name, dp)
+		}
+	}
+	if dp := got.DataPoints["dp5"]; dp.Unit != "" || dp.Value.String() != "5" {
+		t.Errorf("expected failed lookup to leave dp5 unchanged, got %+v", dp)
+	}
+	if got := lookups.Load(); got != 6 {
+		t.Errorf("expected 6 metadata lookups, got %d", got)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("expected a peak of 2 concurrent lookups, got %d", got)
+	}
+}
@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultRangeLookback is how far back datapoint-range looks for data without
+// a lookbackDays parameter.
+const defaultRangeLookback = 365 * 24 * time.Hour
+
+// rangeProbeBuckets is the number of buckets the coarse probe of
+// datapoint-range splits the lookback window into.
+const rangeProbeBuckets = 500
+
+// datapointRange is the response of the datapoint-range resource. Both
+// timestamps are Unix seconds and null if the datapoint has no data.
+type datapointRange struct {
+	First *int64 `json:"first"`
+	Last  *int64 `json:"last"`
+}
+
+// datapointRangeResource serves the datapoint-range resource. WEMS has no
+// call for the extent of a series, so it is derived from bounded probes: one
+// coarsely aggregated query over the lookback window finds the first and last
+// buckets holding data, then one raw query per bucket finds the exact times.
+func (d *Datasource) datapointRangeResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	var params url.Values
+	if parsedUrl, err := url.Parse(req.URL); err == nil {
+		params = parsedUrl.Query()
+	}
+	qm := WEMSQueryModel{
+		EndpointID:  params.Get("endpointId"),
+		ApplianceID: params.Get("applianceId"),
+		ServiceURI:  params.Get("serviceUri"),
+		DataPoint:   params.Get("datapoint"),
+	}
+	if err := validateQueryModel(&qm); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(err.Error()),
+		})
+	}
+	lookback := defaultRangeLookback
+	if v := params.Get("lookbackDays"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   []byte(errInvalidParam("lookbackDays").Error()),
+			})
+		}
+		lookback = time.Duration(days) * 24 * time.Hour
+	}
+
+	result, err := d.probeDatapointRange(ctx, qm, time.Now().Add(-lookback), time.Now())
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte(err.Error()),
+		})
+	}
+	return sendJSON(sender, result)
+}
+
+// probeDatapointRange returns the times of the first and last point of qm
+// between from and to.
+func (d *Datasource) probeDatapointRange(ctx context.Context, qm WEMSQueryModel, from, to time.Time) (datapointRange, error) {
+	noEmpty := false
+	qm.CreateEmptyValues = &noEmpty
+	step := max(to.Sub(from)/rangeProbeBuckets, time.Second).Truncate(time.Second)
+
+	coarse := backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: to}, Interval: step}
+	points, err := d.fetchSeries(ctx, d.seriesPath(qm, coarse))
+	if err != nil {
+		return datapointRange{}, err
+	}
+	first, last, ok := pointExtent(points)
+	if !ok {
+		return datapointRange{}, nil
+	}
+
+	// Bucket timestamps may mark the start or the end of a bucket, so the
+	// raw probes cover a step on either side.
+	refine := func(t int64) (int64, int64, bool, error) {
+		around := backend.DataQuery{TimeRange: backend.TimeRange{
+			From: maxTime(time.Unix(t, 0).Add(-step), from),
+			To:   minTime(time.Unix(t, 0).Add(step), to),
+		}}
+		raw, err := d.fetchSeries(ctx, d.seriesPath(qm, around))
+		if err != nil {
+			return 0, 0, false, err
+		}
+		lo, hi, ok := pointExtent(raw)
+		return lo, hi, ok, nil
+	}
+	if lo, _, ok, err := refine(first); err != nil {
+		return datapointRange{}, err
+	} else if ok {
+		first = lo
+	}
+	if _, hi, ok, err := refine(last); err != nil {
+		return datapointRange{}, err
+	} else if ok {
+		last = hi
+	}
+	return datapointRange{First: &first, Last: &last}, nil
+}
+
+// pointExtent returns the earliest and latest time of the points carrying a
+// value.
+func pointExtent(points []TimeSeriesDataPoint) (first, last int64, ok bool) {
+	for _, p := range points {
+		if p.Value == nil {
+			continue
+		}
+		if !ok || p.Time < first {
+			first = p.Time
+		}
+		if !ok || p.Time > last {
+			last = p.Time
+		}
+		ok = true
+	}
+	return first, last, ok
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDatapointRange(t *testing.T) {
+	now := time.Now().Unix()
+	first, last := now-100*86400+12345, now-2*86400+777
+	stored := []int64{first, first + 60, now - 50*86400, last - 60, last}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("to"), 10, 64)
+		var points []string
+		if v := q.Get("aggregateInterval"); v != "" {
+			step, _ := strconv.ParseInt(strings.TrimSuffix(v, "s"), 10, 64)
+			for bucket := from; bucket < to; bucket += step {
+				value := "null"
+				for _, ts := range stored {
+					if ts >= bucket && ts < bucket+step {
+						value = "1"
+					}
+				}
+				points = append(points, fmt.Sprintf(`{"time":%d,"value":%s}`, bucket, value))
+			}
+		} else {
+			for _, ts := range stored {
+				if ts >= from && ts <= to {
+					points = append(points, fmt.Sprintf(`{"time":%d,"value":1}`, ts))
+				}
+			}
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(points, ",") + "]"))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	base := "datapoint-range?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp"
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "datapoint-range", URL: base})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var got datapointRange
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.First == nil || *got.First != first || got.Last == nil || *got.Last != last {
+		t.Errorf("expected range %d-%d, got %s", first, last, res.Body)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{Path: "datapoint-range", URL: base + "&lookbackDays=1"})
+	if res.Status != http.StatusOK || string(res.Body) != `{"first":null,"last":null}` {
+		t.Errorf("expected an empty range, got %d: %s", res.Status, res.Body)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{Path: "datapoint-range", URL: base + "&lookbackDays=x"})
+	if res.Status != http.StatusBadRequest {
+		t.Errorf("expected invalid lookbackDays to be rejected, got %d", res.Status)
+	}
+}
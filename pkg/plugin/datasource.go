@@ -4,21 +4,54 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math"
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/singleflight"
 )
 
 const DefaultBaseURL = "https://c1.api.wago.com/wems"
 
+// DefaultClientSecretKey is the secure JSON field holding the client secret
+// unless the settings name a different one.
+const DefaultClientSecretKey = "client_secret"
+
+// Token endpoint authentication modes.
+const (
+	// AuthModeJSONBody sends the client credentials in the JSON token request.
+	AuthModeJSONBody = "json_body"
+	// AuthModeBasic sends the client credentials as HTTP Basic auth.
+	AuthModeBasic = "basic"
+)
+
+// tokenFailureBackoff is how long a failed token request is reused as the
+// result of further token requests.
+const tokenFailureBackoff = 5 * time.Second
+
+// tokenRequestTimeout bounds a shared token request, which does not end with
+// the callers waiting for it.
+const tokenRequestTimeout = 10 * time.Second
+
+// ErrMissingQueryFields is returned when a query lacks one of the fields
+// required to address a WEMS series.
+var ErrMissingQueryFields = errors.New("missing required query fields: endpoint_id, appliance_id, service_uri, data_point")
+
 // Make sure Datasource implements required interfaces. This is important to do
 // since otherwise we will only get a not implemented error response from plugin in
 // runtime. In this example datasource instance implements backend.QueryDataHandler,
@@ -27,6 +60,7 @@ const DefaultBaseURL = "https://c1.api.wago.com/wems"
 var (
 	_ backend.QueryDataHandler      = (*Datasource)(nil)
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
@@ -36,9 +70,75 @@ type Datasource struct {
 	clientID     string
 	clientSecret string
 	baseURL      string
-	token        string
-	tokenExpiry  time.Time
-	mutex        sync.Mutex
+	// baseURLs lists all configured gateways, primary first, when failover
+	// is configured. activeBaseURL indexes the one last known to be healthy.
+	baseURLs      []string
+	activeBaseURL atomic.Int32
+	token         string
+	tokenExpiry   time.Time
+	mutex         sync.Mutex
+	// tokenErr is the last token request failure; getTokenIfNeeded returns it
+	// without contacting WEMS until tokenRetryAt.
+	tokenErr     error
+	tokenRetryAt time.Time
+
+	// watchdogCancel stops the token watchdog; watchdogDone is closed once it
+	// has exited. Both are nil when the watchdog is disabled.
+	watchdogCancel context.CancelFunc
+	watchdogDone   chan struct{}
+
+	// prefetchCancel stops the list prefetch; prefetchDone is closed once it
+	// has exited. Both are nil when EnableListPrefetch is off.
+	prefetchCancel context.CancelFunc
+	prefetchDone   chan struct{}
+
+	// endpointTokens caches the tokens scoped to a single endpoint used
+	// with PerEndpointTokens, keyed by endpoint ID.
+	endpointTokensMu sync.Mutex
+	endpointTokens   map[string]scopedToken
+
+	// endpointTokenGroup shares one token request per endpoint between
+	// concurrent callers.
+	endpointTokenGroup singleflight.Group
+
+	// transport is shared by all WEMS requests; nil uses the default one.
+	transport http.RoundTripper
+
+	// minInterval is the parsed MinInterval setting.
+	minInterval time.Duration
+
+	// settings holds the options parsed from the datasource configuration.
+	settings DatasourceSettings
+
+	// seriesGroup shares one upstream call between concurrent identical
+	// series requests.
+	seriesGroup singleflight.Group
+
+	// tokenGroup shares one token request between concurrent refreshes.
+	tokenGroup singleflight.Group
+
+	// configDescriptions caches endpoint descriptions including appliance
+	// configuration, keyed by endpoint and draft flag.
+	configDescriptions ttlCache[[]byte]
+
+	// lists caches endpoint lists and descriptions for the search and paged
+	// endpoint-list resources, keyed by URL. EnableListPrefetch fills it in
+	// the background.
+	lists ttlCache[[]byte]
+
+	// lastGood keeps the frames of successful queries by query signature
+	// for ServeStaleOnError.
+	lastGood ttlCache[lastGood]
+
+	// etags holds resource bodies WEMS sent with an ETag, keyed by URL,
+	// for conditional requests.
+	etags ttlCache[etagEntry]
+
+	// models caches appliance model names by appliance reference.
+	models ttlCache[string]
+
+	// streams tracks running streams so Dispose can stop them.
+	streams streamRegistry
 }
 
 // TokenRequest is the payload for the WEMS token endpoint
@@ -46,8 +146,8 @@ type Datasource struct {
 // See OpenAPI for full structure
 type TokenRequest struct {
 	ApplicationComponents map[string][]string `json:"application_components"`
-	ClientID              string              `json:"client_id"`
-	ClientSecret          string              `json:"client_secret"`
+	ClientID              string              `json:"client_id,omitempty"`
+	ClientSecret          string              `json:"client_secret,omitempty"`
 	Endpoints             map[string][]string `json:"endpoints"`
 	PlatformScopes        []string            `json:"platform_scopes"`
 	SuperToken            bool                `json:"super_token"`
@@ -58,16 +158,276 @@ type DatasourceSettings struct {
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	BaseURL      string `json:"base_url"`
+	// BaseURLs lists redundant WEMS gateways to fail over between, either as
+	// a JSON array or a comma-separated string. It takes precedence over
+	// BaseURL when set.
+	BaseURLs baseURLList `json:"base_urls"`
+	// ClientSecretKey names the secure JSON field holding the client secret.
+	ClientSecretKey string `json:"client_secret_key"`
+	// EnableTokenWatchdog refreshes the token in the background before it
+	// expires instead of on the next request.
+	EnableTokenWatchdog bool `json:"enable_token_watchdog"`
+	// TreatAsEmpty lists upstream HTTP statuses that yield an empty series
+	// instead of an error, e.g. 404 for a datapoint without data in range.
+	TreatAsEmpty []int `json:"treat_as_empty"`
+	// StatusActions maps upstream HTTP statuses of series requests to
+	// StatusActionError, StatusActionEmpty or StatusActionRetry, e.g.
+	// {"503": "empty"}. Unlisted statuses yield an empty series if in
+	// TreatAsEmpty, are retried if 429 or 5xx and fail the query otherwise.
+	StatusActions map[int]string `json:"status_actions"`
+	// DecimalSeparator and GroupSeparator describe how numbers sent as
+	// strings are formatted, e.g. "," and "." for "1.234,56". The default is
+	// a "." decimal separator without grouping.
+	DecimalSeparator string `json:"decimal_separator"`
+	GroupSeparator   string `json:"group_separator"`
+	// DefaultLimit is sent as the series limit for queries without
+	// MaxDataPoints, such as alerting queries.
+	DefaultLimit int `json:"default_limit"`
+	// LimitNotice attaches a warning to series whose point count reaches
+	// the limit sent to WEMS, as those were most likely cut short.
+	LimitNotice bool `json:"limit_notice"`
+	// OnMixedType decides what happens to a series mixing value types:
+	// MixedTypeCoerce (default), MixedTypeError or MixedTypeString.
+	OnMixedType string `json:"on_mixed_type"`
+	// FrameFormat selects the layout of query responses: FrameFormatLong
+	// (default) returns one frame per series, FrameFormatWide joins all
+	// series of a query into a single wide time series frame.
+	FrameFormat string `json:"frame_format"`
+	// SeriesAccept is the Accept header sent with series requests. WEMS may
+	// answer with JSON (default) or newline-delimited JSON.
+	SeriesAccept string `json:"series_accept"`
+	// MaxResponseBytes caps the size of a decoded series response, whether
+	// or not WEMS sends a Content-Length. Defaults to DefaultMaxResponseBytes.
+	MaxResponseBytes int64 `json:"max_response_bytes"`
+	// MaxPoints caps the number of points of a decoded series. Defaults to
+	// DefaultMaxPoints. OnMaxPoints decides what happens to larger series:
+	// MaxPointsTruncate (default) or MaxPointsError.
+	MaxPoints   int    `json:"max_points"`
+	OnMaxPoints string `json:"on_max_points"`
+	// OnNonFinite decides what happens to NaN and infinite values after
+	// conversion: NonFiniteNull (default) or NonFiniteError. It applies to
+	// series frames as well as summaries, histograms, ratios and CSV exports.
+	OnNonFinite string `json:"on_non_finite"`
+	// CompressRequests gzips the body of token requests and sends it with
+	// Content-Encoding: gzip. Only enable it for WEMS gateways that accept
+	// compressed request bodies.
+	CompressRequests bool `json:"compress_requests"`
+	// InferUnits sets the unit of series without an explicit unit from a
+	// suffix of the data point or service name, such as power_kW.
+	InferUnits bool `json:"infer_units"`
+	// ConditionalRequests caches resource bodies WEMS sends with an ETag and
+	// revalidates them with If-None-Match, reusing them on 304 Not Modified.
+	ConditionalRequests bool `json:"conditional_requests"`
+	// QueryDelaySeconds accounts for WEMS ingestion lag: series requests end
+	// at most this long before now, so the not yet written latest seconds
+	// don't show as a drop. Zero disables the delay.
+	QueryDelaySeconds int `json:"query_delay_seconds"`
+	// ProcessLabels sets a "process" label on the value fields of series
+	// queries, naming the process of the appliance as listed in the
+	// endpoint description. It costs a cached description lookup per query.
+	ProcessLabels bool `json:"process_labels"`
+	// ModelLabels sets a "model" label on the value fields of series
+	// queries, naming the appliance model as listed by appliance-list. It
+	// costs a cached description and model lookup per query.
+	ModelLabels bool `json:"model_labels"`
+	// MaxRetries retries series requests failing with a connection error,
+	// 429 or 5xx up to this many times. RetryBudget caps the retries of all
+	// queries of one QueryData call together, defaulting to
+	// defaultRetryBudget; once it is used up the remaining queries of the
+	// call fail without contacting WEMS.
+	MaxRetries  int `json:"max_retries"`
+	RetryBudget int `json:"retry_budget"`
+	// RetryTruncated repeats a series request once if its response ends
+	// before the JSON document does, counting against RetryBudget.
+	RetryTruncated bool `json:"retry_truncated"`
+	// ErrorVerbosity controls how much of a failed query's error reaches
+	// the panel: ErrorVerbosityFull (default) or ErrorVerbosityMinimal.
+	ErrorVerbosity string `json:"error_verbosity"`
+	// Timezone is an IANA zone name sent with series requests, for WEMS
+	// installations that aggregate in a local zone. Unset sends no zone.
+	Timezone string `json:"timezone"`
+	// BoolMapping sets the numbers boolean values are converted to. Unset
+	// values default to 1 for true and 0 for false.
+	BoolMapping BoolMapping `json:"bool_mapping"`
+	// VerboseLogging logs every WEMS request and response, including
+	// headers and the start of the body, at debug level. Credentials are
+	// redacted.
+	VerboseLogging bool `json:"verbose_logging"`
+	// SoftErrors returns failed queries as an empty frame with an error
+	// notice instead of an error response, so other series stay visible.
+	SoftErrors bool `json:"soft_errors"`
+	// ModelLookupConcurrency caps the number of appliance model lookups
+	// appliance-list runs at the same time. Defaults to 8.
+	ModelLookupConcurrency int `json:"model_lookup_concurrency"`
+	// MetadataLookupConcurrency caps the number of datapoint metadata
+	// lookups datapoint-list runs at the same time. Defaults to 8.
+	MetadataLookupConcurrency int `json:"metadata_lookup_concurrency"`
+	// ServeStaleOnError answers a query whose fetch failed with the frames
+	// of its last successful run, if any, marked with a warning.
+	ServeStaleOnError bool `json:"serve_stale_on_error"`
+	// CompressCachedFrames keeps the frames cached for ServeStaleOnError
+	// gzip-compressed, trading CPU on every query for memory.
+	CompressCachedFrames bool `json:"compress_cached_frames"`
+	// DefaultAggregateFunction is sent for queries without an aggregate
+	// function. Queries opt out with AggregateNone.
+	DefaultAggregateFunction string `json:"default_aggregate_function"`
+	// WarnOnAllZeroCoerced attaches a warning to frames whose values all
+	// failed numeric conversion and were replaced by 0.
+	WarnOnAllZeroCoerced bool `json:"warn_on_all_zero_coerced"`
+	// TLSCACertFile is the path of a PEM CA bundle trusted for WEMS in
+	// addition to the system roots.
+	TLSCACertFile string `json:"tls_ca_cert_file"`
+	// IdleConnTimeoutSeconds is how long idle connections to WEMS are kept
+	// for reuse (default 90). KeepAliveSeconds is the TCP keep-alive probe
+	// interval (default 30, negative disables probes). DisableKeepAlives
+	// opens a new connection for every request.
+	IdleConnTimeoutSeconds int  `json:"idle_conn_timeout_seconds"`
+	KeepAliveSeconds       int  `json:"keep_alive_seconds"`
+	DisableKeepAlives      bool `json:"disable_keep_alives"`
+	// MaxIdleConnsPerHost is the number of idle connections kept per WEMS
+	// host (default 16, enough for a busy dashboard to reuse connections).
+	// MaxConnsPerHost caps the connections per host (default unlimited).
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int `json:"max_conns_per_host"`
+	// MinInterval is a floor for the aggregation interval sent to WEMS, as
+	// a Go duration such as "1m".
+	MinInterval string `json:"min_interval"`
+	// PerEndpointTokens makes queries authenticate with a token scoped to
+	// the queried endpoint instead of the super token. Tokens are cached
+	// per endpoint.
+	PerEndpointTokens bool `json:"per_endpoint_tokens"`
+	// SanitizeNames turns the names derived from endpoint, appliance,
+	// service URI and datapoint into legend-friendly words, e.g.
+	// "Ep1 Meter Active Power". Display names are kept as given.
+	SanitizeNames bool `json:"sanitize_names"`
+	// HealthCheckTimeoutSeconds bounds the token request of a health check,
+	// so "Save & Test" fails fast. Defaults to 10 seconds.
+	HealthCheckTimeoutSeconds int `json:"health_check_timeout_seconds"`
+	// HealthCheckSoftFail makes health checks that cannot reach WEMS pass
+	// with a warning, so a transient outage doesn't fail provisioning.
+	// Invalid settings and rejected credentials still fail.
+	HealthCheckSoftFail bool `json:"health_check_soft_fail"`
+	// EnableListPrefetch refreshes the endpoint list and endpoint
+	// descriptions in the background every ListPrefetchIntervalSeconds
+	// (default 300), so endpoint-list and appliance-list answer from cache.
+	EnableListPrefetch          bool `json:"enable_list_prefetch"`
+	ListPrefetchIntervalSeconds int  `json:"list_prefetch_interval_seconds"`
+	// AuthMode selects how credentials are sent to the token endpoint:
+	// AuthModeJSONBody (default) or AuthModeBasic.
+	AuthMode string `json:"auth_mode"`
+}
+
+// BoolMapping maps boolean values to numbers, e.g. to invert states.
+type BoolMapping struct {
+	TrueValue  *float64 `json:"true_value"`
+	FalseValue *float64 `json:"false_value"`
 }
 
 // NewDatasource creates a new datasource instance.
 func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	dsSettings, err := loadSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(dsSettings)
+	if err != nil {
+		return nil, err
+	}
+	ds := &Datasource{
+		clientID:     dsSettings.ClientID,
+		clientSecret: dsSettings.ClientSecret,
+		baseURL:      dsSettings.BaseURL,
+		baseURLs:     dsSettings.BaseURLs,
+		transport:    transport,
+		settings:     dsSettings,
+	}
+	ds.minInterval, _ = time.ParseDuration(dsSettings.MinInterval)
+	// Get initial token
+	if err := ds.getTokenIfNeeded(context.Background()); err != nil {
+		return nil, err
+	}
+	if dsSettings.EnableTokenWatchdog {
+		ds.startTokenWatchdog()
+	}
+	if dsSettings.EnableListPrefetch {
+		ds.startListPrefetch(ds.listPrefetchInterval())
+	}
+	return ds, nil
+}
+
+// loadSettings parses the datasource configuration, applying defaults and
+// rejecting unsupported option values.
+func loadSettings(settings backend.DataSourceInstanceSettings) (DatasourceSettings, error) {
 	var dsSettings DatasourceSettings
 	if err := json.Unmarshal(settings.JSONData, &dsSettings); err != nil {
-		return nil, fmt.Errorf("failed to parse datasource settings: %w", err)
+		return dsSettings, fmt.Errorf("failed to parse datasource settings: %w", err)
+	}
+	if dsSettings.ClientSecretKey == "" {
+		dsSettings.ClientSecretKey = DefaultClientSecretKey
+	}
+	switch dsSettings.AuthMode {
+	case "":
+		dsSettings.AuthMode = AuthModeJSONBody
+	case AuthModeJSONBody, AuthModeBasic:
+	default:
+		return dsSettings, fmt.Errorf("unsupported auth_mode %q", dsSettings.AuthMode)
+	}
+	switch dsSettings.FrameFormat {
+	case "":
+		dsSettings.FrameFormat = FrameFormatLong
+	case FrameFormatLong, FrameFormatWide:
+	default:
+		return dsSettings, fmt.Errorf("unsupported frame_format %q", dsSettings.FrameFormat)
+	}
+	switch dsSettings.OnMixedType {
+	case "":
+		dsSettings.OnMixedType = MixedTypeCoerce
+	case MixedTypeCoerce, MixedTypeError, MixedTypeString:
+	default:
+		return dsSettings, fmt.Errorf("unsupported on_mixed_type %q", dsSettings.OnMixedType)
+	}
+	switch dsSettings.OnMaxPoints {
+	case "":
+		dsSettings.OnMaxPoints = MaxPointsTruncate
+	case MaxPointsTruncate, MaxPointsError:
+	default:
+		return dsSettings, fmt.Errorf("unsupported on_max_points %q", dsSettings.OnMaxPoints)
+	}
+	switch dsSettings.OnNonFinite {
+	case "":
+		dsSettings.OnNonFinite = NonFiniteNull
+	case NonFiniteNull, NonFiniteError:
+	default:
+		return dsSettings, fmt.Errorf("unsupported on_non_finite %q", dsSettings.OnNonFinite)
+	}
+	if dsSettings.QueryDelaySeconds < 0 {
+		return dsSettings, fmt.Errorf("invalid query_delay_seconds %d: must not be negative", dsSettings.QueryDelaySeconds)
+	}
+	if dsSettings.MaxRetries < 0 || dsSettings.RetryBudget < 0 {
+		return dsSettings, fmt.Errorf("invalid max_retries %d or retry_budget %d: must not be negative", dsSettings.MaxRetries, dsSettings.RetryBudget)
+	}
+	if err := validateStatusActions(dsSettings.StatusActions); err != nil {
+		return dsSettings, err
+	}
+	switch dsSettings.ErrorVerbosity {
+	case "":
+		dsSettings.ErrorVerbosity = ErrorVerbosityFull
+	case ErrorVerbosityFull, ErrorVerbosityMinimal:
+	default:
+		return dsSettings, fmt.Errorf("unsupported error_verbosity %q", dsSettings.ErrorVerbosity)
+	}
+	if dsSettings.MinInterval != "" {
+		if d, err := time.ParseDuration(dsSettings.MinInterval); err != nil || d < 0 {
+			return dsSettings, fmt.Errorf("invalid min_interval %q", dsSettings.MinInterval)
+		}
+	}
+	if dsSettings.Timezone != "" {
+		if _, err := time.LoadLocation(dsSettings.Timezone); err != nil {
+			return dsSettings, fmt.Errorf("unsupported timezone %q: %w", dsSettings.Timezone, err)
+		}
 	}
 	if settings.DecryptedSecureJSONData != nil {
-		if v, ok := settings.DecryptedSecureJSONData["client_secret"]; ok {
+		if v, ok := settings.DecryptedSecureJSONData[dsSettings.ClientSecretKey]; ok {
 			dsSettings.ClientSecret = v
 		}
 	}
@@ -80,26 +440,83 @@ func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSetting
 	if len(dsSettings.BaseURL) > 0 && dsSettings.BaseURL[len(dsSettings.BaseURL)-1] == '/' {
 		dsSettings.BaseURL = dsSettings.BaseURL[:len(dsSettings.BaseURL)-1]
 	}
-	ds := &Datasource{
-		clientID:     dsSettings.ClientID,
-		clientSecret: dsSettings.ClientSecret,
-		baseURL:      dsSettings.BaseURL,
+	for i, u := range dsSettings.BaseURLs {
+		dsSettings.BaseURLs[i] = strings.TrimSuffix(u, "/")
 	}
-	// Get initial token
-	if err := ds.getTokenIfNeeded(context.Background()); err != nil {
-		return nil, err
+	if len(dsSettings.BaseURLs) > 0 {
+		dsSettings.BaseURL = dsSettings.BaseURLs[0]
 	}
-	return ds, nil
+	return dsSettings, nil
 }
 
 // getTokenIfNeeded checks token expiration and refreshes the token if needed.
 func (d *Datasource) getTokenIfNeeded(ctx context.Context) error {
 	d.mutex.Lock()
-	defer d.mutex.Unlock()
-	if d.token != "" && time.Now().Before(d.tokenExpiry.Add(-1*time.Minute)) {
-		return nil // Token is still valid (with 1 min buffer)
+	if d.tokenValid() {
+		d.mutex.Unlock()
+		return nil
+	}
+	// Fail fast while the token endpoint recently failed, so concurrent
+	// queries don't all retry it in lockstep
+	if d.tokenErr != nil && time.Now().Before(d.tokenRetryAt) {
+		err := d.tokenErr
+		d.mutex.Unlock()
+		return err
+	}
+	d.mutex.Unlock()
+	return d.requestToken(ctx, false)
+}
+
+// tokenValid reports whether the current token is still valid (with 1 min
+// buffer). The caller must hold d.mutex.
+func (d *Datasource) tokenValid() bool {
+	return d.token != "" && time.Now().Before(d.tokenExpiry.Add(-1*time.Minute))
+}
+
+// requestToken fetches a new token from WEMS. Concurrent callers share a
+// single token request and its result; unless force is set, a caller joining
+// after another one stored a valid token does not request a new one. The
+// request runs detached from the callers, each of which stops waiting when
+// its own ctx is done. A failure is returned to further callers until
+// tokenFailureBackoff passed; cancellations and timeouts are not recorded.
+func (d *Datasource) requestToken(ctx context.Context, force bool) error {
+	ch := d.tokenGroup.DoChan("token", func() (interface{}, error) {
+		if !force {
+			d.mutex.Lock()
+			valid := d.tokenValid()
+			d.mutex.Unlock()
+			if valid {
+				return nil, nil
+			}
+		}
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRequestTimeout)
+		defer cancel()
+		token, err := d.fetchToken(fetchCtx, "")
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if err != nil {
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				d.tokenErr = err
+				d.tokenRetryAt = time.Now().Add(tokenFailureBackoff)
+			}
+			return nil, err
+		}
+		d.token = token
+		d.tokenExpiry = time.Now().Add(30 * time.Minute) // WEMS tokens are valid for 20 min
+		d.tokenErr = nil
+		return nil, nil
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	// Request new token
+}
+
+// fetchToken requests a token from WEMS: a super token, or one scoped to
+// endpointID if it is not empty.
+func (d *Datasource) fetchToken(ctx context.Context, endpointID string) (string, error) {
 	tokenReq := TokenRequest{
 		ApplicationComponents: map[string][]string{},
 		ClientID:              d.clientID,
@@ -108,33 +525,69 @@ func (d *Datasource) getTokenIfNeeded(ctx context.Context) error {
 		PlatformScopes:        []string{},
 		SuperToken:            true,
 	}
-	tokenURL := d.baseURL + "/v1/token"
+	if endpointID != "" {
+		tokenReq.Endpoints[endpointID] = []string{}
+		tokenReq.SuperToken = false
+	}
+	if d.settings.AuthMode == AuthModeBasic {
+		tokenReq.ClientID = ""
+		tokenReq.ClientSecret = ""
+	}
 	body, err := json.Marshal(tokenReq)
 	if err != nil {
-		return fmt.Errorf("failed to marshal token request: %w", err)
+		return "", fmt.Errorf("failed to marshal token request: %w", err)
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("failed to create token request: %w", err)
+	if d.settings.CompressRequests {
+		if body, err = gzipBody(body); err != nil {
+			return "", fmt.Errorf("failed to compress token request: %w", err)
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	client := d.httpClient(10 * time.Second)
+	resp, err := d.doWithFailover(client, func(baseURL string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/token", bytes.NewBuffer(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create token request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if d.settings.CompressRequests {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		if d.settings.AuthMode == AuthModeBasic {
+			req.SetBasicAuth(d.clientID, d.clientSecret)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get WEMS token: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrTokenNetwork, err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
+	// Some gateways answer with 201 or 202, so any 2xx carries a token
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("WEMS token request failed: %s %s", resp.Status, string(bodyBytes))
+		return "", fmt.Errorf("%w: %s %s (request ID %s)", tokenStatusError(resp.StatusCode), resp.Status, string(bodyBytes), requestIDOf(resp))
 	}
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read token response: %w", err)
+		return "", fmt.Errorf("%w: failed to read token response: %w", ErrTokenNetwork, err)
 	}
-	d.token = string(bodyBytes)
-	d.tokenExpiry = time.Now().Add(30 * time.Minute) // WEMS tokens are valid for 20 min
-	return nil
+	token := parseTokenBody(bodyBytes)
+	if token == "" {
+		return "", fmt.Errorf("%w: empty token (request ID %s)", ErrTokenBadResponse, requestIDOf(resp))
+	}
+	return token, nil
+}
+
+// parseTokenBody returns the token in a token response body. Some gateways
+// send the token as a JSON string literal rather than plain text.
+func parseTokenBody(body []byte) string {
+	token := strings.TrimSpace(string(body))
+	if strings.HasPrefix(token, `"`) {
+		var unquoted string
+		if err := json.Unmarshal([]byte(token), &unquoted); err == nil {
+			return unquoted
+		}
+	}
+	return token
 }
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
@@ -142,6 +595,17 @@ func (d *Datasource) getTokenIfNeeded(ctx context.Context) error {
 // be disposed and a new one will be created using NewSampleDatasource factory function.
 func (d *Datasource) Dispose() {
 	// Clean up datasource instance resources.
+	if d.watchdogCancel != nil {
+		d.watchdogCancel()
+		<-d.watchdogDone
+	}
+	if d.prefetchCancel != nil {
+		d.prefetchCancel()
+		<-d.prefetchDone
+	}
+	if stuck := d.streams.drain(streamDrainTimeout); len(stuck) > 0 {
+		log.DefaultLogger.Warn("Streams did not stop on dispose", "paths", stuck)
+	}
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -153,73 +617,769 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 	response := backend.NewQueryDataResponse()
 
 	// loop over queries and execute them individually.
+	refIDs := make(map[string]int, len(req.Queries))
+	if d.settings.MaxRetries > 0 {
+		ctx = withRetryBudget(ctx, d.retryBudget())
+	}
 	for _, q := range req.Queries {
 		res := d.query(ctx, req.PluginContext, q)
+		if d.settings.ErrorVerbosity == ErrorVerbosityMinimal && res.Error != nil {
+			res = d.minimalErrorResponse(q.RefID, res)
+		}
+		if d.settings.SoftErrors && res.Error != nil {
+			res = softErrorResponse(res)
+		}
 
 		// save the response in a hashmap
-		// based on with RefID as identifier
+		// based on with RefID as identifier; for a reused RefID the last
+		// query wins
 		response.Responses[q.RefID] = res
+		refIDs[q.RefID]++
+	}
+	for refID, n := range refIDs {
+		if n < 2 {
+			continue
+		}
+		res := response.Responses[refID]
+		appendResponseNotice(&res, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("RefID %s is used by %d queries, only the last one is shown", refID, n),
+		})
+		response.Responses[refID] = res
+	}
+
+	return response, nil
+}
+
+// appendResponseNotice attaches notice to the first frame of res, adding an
+// empty frame if res has none.
+func appendResponseNotice(res *backend.DataResponse, notice data.Notice) {
+	if len(res.Frames) == 0 {
+		res.Frames = data.Frames{data.NewFrame("")}
+	}
+	res.Frames[0].AppendNotices(notice)
+}
+
+// softErrorResponse turns a failed query response into an empty frame that
+// carries the error as a notice, so the panel keeps showing its other series.
+func softErrorResponse(res backend.DataResponse) backend.DataResponse {
+	frame := data.NewFrame("")
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityError,
+		Text:     res.Error.Error(),
+	})
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// maxAggregateInterval caps the aggregate interval of series requests, which
+// Grafana derives from the time range and can reach years on wide ranges.
+const maxAggregateInterval = 30 * 24 * time.Hour
+
+// AggregateNone as aggregate function requests the raw series, sending neither
+// an aggregate function nor an interval even if a default is configured.
+const AggregateNone = "none"
+
+type WEMSQueryModel struct {
+	EndpointID        string   `json:"endpoint_id"`
+	ApplianceID       string   `json:"appliance_id"`
+	ServiceURI        string   `json:"service_uri"`
+	DataPoint         string   `json:"data_point"`
+	AggregateFunction string   `json:"aggregate_function,omitempty"`
+	CreateEmptyValues *bool    `json:"create_empty_values,omitempty"`
+	Unit              string   `json:"unit,omitempty"`
+	ValidValues       []string `json:"validValues,omitempty"`
+	// Precision rounds values to the given number of decimals. Unset or
+	// negative leaves values untouched.
+	Precision *int `json:"precision,omitempty"`
+	// DisplayDecimals sets the decimals Grafana displays values with,
+	// independent from Precision. Unset or negative keeps Grafana's
+	// automatic formatting.
+	DisplayDecimals *int `json:"display_decimals,omitempty"`
+	// DedupeTimestamps collapses points sharing a timestamp into one, keeping
+	// the value selected by DedupeKeep ("last" by default, or "first"), or
+	// by BucketReducer if set.
+	DedupeTimestamps bool   `json:"dedupe_timestamps,omitempty"`
+	DedupeKeep       string `json:"dedupe_keep,omitempty"`
+	// ScopedVars carries dashboard variables that were not interpolated by
+	// the frontend, keyed by variable name.
+	ScopedVars map[string]ScopedVar `json:"scopedVars,omitempty"`
+	// StaleAfterSeconds attaches a warning to the frame when the newest point
+	// is older than this many seconds. Zero disables the check.
+	StaleAfterSeconds int64 `json:"stale_after_seconds,omitempty"`
+	// MultiResolution returns a coarse overview of the whole range plus a
+	// detail frame at the panel interval for its last DetailSeconds.
+	MultiResolution bool  `json:"multi_resolution,omitempty"`
+	DetailSeconds   int64 `json:"detail_seconds,omitempty"`
+	// DisplayName and Color set the display name and fixed color of the
+	// value field.
+	DisplayName string `json:"display_name,omitempty"`
+	Color       string `json:"color,omitempty"`
+	// Mode selects what is queried: the series over the time range (default),
+	// QueryModeCurrent for only the latest value or QueryModeRaw for the
+	// series with unconverted string values.
+	Mode string `json:"mode,omitempty"`
+	// Targets queries several service/datapoint pairs of the appliance at
+	// once, returning one frame per target. ServiceURI and DataPoint are
+	// ignored when set.
+	Targets []QueryTarget `json:"targets,omitempty"`
+	// ResampleStep resamples the series onto a uniform grid with this step
+	// (a Go duration such as "1m") over the time range. FillMode selects
+	// how empty slots are filled: FillModeNone (default), FillModeForward
+	// or FillModeZero.
+	ResampleStep string `json:"resample_step,omitempty"`
+	FillMode     string `json:"fill_mode,omitempty"`
+	// BucketReducer selects the value kept when several points fall into
+	// the same resampling slot, calendar bucket or timestamp:
+	// BucketReducerFirst, BucketReducerLast, BucketReducerMax,
+	// BucketReducerMin, BucketReducerMean or BucketReducerSum. The default
+	// is BucketReducerLast, or for calendar buckets the reducer matching
+	// the aggregate function, see calendarReducer.
+	BucketReducer string `json:"bucket_reducer,omitempty"`
+	// CalendarBucket buckets the series by calendar period instead of a
+	// fixed duration: CalendarBucketDay, CalendarBucketWeek or
+	// CalendarBucketMonth. Buckets are aligned in CalendarTimezone, an IANA
+	// zone name defaulting to the datasource timezone, then UTC.
+	CalendarBucket   string `json:"calendar_bucket,omitempty"`
+	CalendarTimezone string `json:"calendar_timezone,omitempty"`
+	// JoinTolerance lets the wide frame format join points of different
+	// series up to this far apart (a Go duration such as "5s") into one
+	// row. Unset requires exact timestamps.
+	JoinTolerance string `json:"join_tolerance,omitempty"`
+	// ClampMin and ClampMax bound the values of the series frame to filter
+	// out sensor glitches. ClampMode selects what happens to values outside
+	// the bounds: ClampModeDrop (default) or ClampModeClamp.
+	ClampMin  *float64 `json:"clamp_min,omitempty"`
+	ClampMax  *float64 `json:"clamp_max,omitempty"`
+	ClampMode string   `json:"clamp_mode,omitempty"`
+	// Histogram returns a histogram of the series values over the time range
+	// instead of the series, with Buckets equally wide buckets (default
+	// defaultHistogramBuckets). It applies to single-series queries.
+	Histogram bool `json:"histogram,omitempty"`
+	Buckets   int  `json:"buckets,omitempty"`
+	// SummaryStats adds a one-row frame with the min, max, avg, sum and last
+	// value of the series. It applies to single-series queries.
+	SummaryStats bool `json:"summary_stats,omitempty"`
+	// ApplianceIDs queries the service/datapoint on several appliances of
+	// the endpoint at once, returning one frame per appliance. ApplianceID
+	// and Targets are ignored when set.
+	ApplianceIDs []string `json:"appliance_ids,omitempty"`
+	// LTTB decimates series with more points than the query's MaxDataPoints
+	// down to that count with the largest-triangle-three-buckets algorithm.
+	LTTB bool `json:"lttb,omitempty"`
+	// SplitAggregates fetches each function of a comma-separated
+	// AggregateFunction separately and returns one frame per function.
+	SplitAggregates bool `json:"split_aggregates,omitempty"`
+	// Numerator and Denominator query two service/datapoint pairs of the
+	// appliance and return their ratio per timestamp as a single frame.
+	// ServiceURI and DataPoint are ignored when set.
+	Numerator   *QueryTarget `json:"numerator,omitempty"`
+	Denominator *QueryTarget `json:"denominator,omitempty"`
+	// TimeoutSeconds overrides the datasource timeout of the WEMS requests
+	// made for this query, e.g. for wide ranges. Zero keeps the default.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// EndpointIDs limits fleet status queries to these endpoints instead of
+	// all endpoints.
+	EndpointIDs []string `json:"endpoint_ids,omitempty"`
+}
+
+// QueryTarget is one service/datapoint pair of a multi-target query.
+type QueryTarget struct {
+	ServiceURI string `json:"service_uri"`
+	DataPoint  string `json:"data_point"`
+}
+
+// Query modes, see WEMSQueryModel.Mode.
+const (
+	// QueryModeCurrent queries only the current value of a datapoint.
+	QueryModeCurrent = "current"
+	// QueryModeRaw queries the series and returns every value as a string,
+	// without numeric conversion, for text-like datapoints.
+	QueryModeRaw = "raw"
+)
+
+// ScopedVar is a dashboard variable value as sent by Grafana.
+type ScopedVar struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+var templateVarRegexp = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+// interpolate replaces $var and ${var} references in s with values from vars.
+// Unknown variables are left untouched.
+func interpolate(s string, vars map[string]ScopedVar) string {
+	if len(vars) == 0 {
+		return s
+	}
+	return templateVarRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		sub := templateVarRegexp.FindStringSubmatch(m)
+		name := sub[1]
+		if name == "" {
+			name = sub[2]
+		}
+		if v, ok := vars[name]; ok {
+			return v.Value
+		}
+		return m
+	})
+}
+
+// interpolateQueryModel substitutes the scoped variables of qm in all of its
+// endpoint, appliance, service and datapoint IDs, including those of its
+// targets and ratio operands.
+func interpolateQueryModel(qm *WEMSQueryModel) {
+	vars := qm.ScopedVars
+	for _, s := range []*string{&qm.EndpointID, &qm.ApplianceID, &qm.ServiceURI, &qm.DataPoint} {
+		*s = interpolate(*s, vars)
+	}
+	for _, ids := range [][]string{qm.ApplianceIDs, qm.EndpointIDs} {
+		for i := range ids {
+			ids[i] = interpolate(ids[i], vars)
+		}
+	}
+	for i := range qm.Targets {
+		qm.Targets[i].interpolate(vars)
+	}
+	for _, t := range []*QueryTarget{qm.Numerator, qm.Denominator} {
+		if t != nil {
+			t.interpolate(vars)
+		}
+	}
+}
+
+// interpolate substitutes the scoped variables vars in the IDs of t.
+func (t *QueryTarget) interpolate(vars map[string]ScopedVar) {
+	t.ServiceURI = interpolate(t.ServiceURI, vars)
+	t.DataPoint = interpolate(t.DataPoint, vars)
+}
+
+type TimeSeriesDataPoint struct {
+	Time  int64       `json:"time"`
+	Value interface{} `json:"value"`
+	// Interpolated marks points WEMS or resampling filled in rather than
+	// measured. It is nil when the source does not say.
+	Interpolated *bool `json:"interpolated,omitempty"`
+}
+
+// validateQueryModel trims the fields used to build the series URL and
+// returns ErrMissingQueryFields if any of them is empty afterwards. Invalid
+// resampling options are reported as well.
+func validateQueryModel(qm *WEMSQueryModel) error {
+	qm.EndpointID = strings.TrimSpace(qm.EndpointID)
+	qm.ApplianceID = strings.TrimSpace(qm.ApplianceID)
+	qm.ServiceURI = strings.TrimSpace(qm.ServiceURI)
+	qm.DataPoint = strings.TrimSpace(qm.DataPoint)
+	if _, err := parseResampleStep(*qm); err != nil {
+		return err
+	}
+	if err := validateBucketReducer(qm.BucketReducer); err != nil {
+		return err
+	}
+	if err := validateCalendarBucket(*qm); err != nil {
+		return err
+	}
+	if _, err := parseJoinTolerance(*qm); err != nil {
+		return err
+	}
+	if err := validateHistogram(*qm); err != nil {
+		return err
+	}
+	if err := validateClamp(*qm); err != nil {
+		return err
+	}
+	if qm.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid timeout_seconds %d: must be positive", qm.TimeoutSeconds)
+	}
+	if len(qm.ApplianceIDs) > 0 {
+		if qm.EndpointID == "" || qm.ServiceURI == "" || qm.DataPoint == "" {
+			return ErrMissingQueryFields
+		}
+		for i := range qm.ApplianceIDs {
+			qm.ApplianceIDs[i] = strings.TrimSpace(qm.ApplianceIDs[i])
+			if qm.ApplianceIDs[i] == "" {
+				return ErrMissingQueryFields
+			}
+		}
+		return nil
+	}
+	if qm.Numerator != nil || qm.Denominator != nil {
+		if qm.EndpointID == "" || qm.ApplianceID == "" || qm.Numerator == nil || qm.Denominator == nil {
+			return ErrMissingQueryFields
+		}
+		for _, target := range []*QueryTarget{qm.Numerator, qm.Denominator} {
+			target.ServiceURI = strings.TrimSpace(target.ServiceURI)
+			target.DataPoint = strings.TrimSpace(target.DataPoint)
+			if target.ServiceURI == "" || target.DataPoint == "" {
+				return ErrMissingQueryFields
+			}
+		}
+		return nil
+	}
+	if len(qm.Targets) > 0 {
+		if qm.EndpointID == "" || qm.ApplianceID == "" {
+			return ErrMissingQueryFields
+		}
+		for i := range qm.Targets {
+			qm.Targets[i].ServiceURI = strings.TrimSpace(qm.Targets[i].ServiceURI)
+			qm.Targets[i].DataPoint = strings.TrimSpace(qm.Targets[i].DataPoint)
+			if qm.Targets[i].ServiceURI == "" || qm.Targets[i].DataPoint == "" {
+				return ErrMissingQueryFields
+			}
+		}
+		return nil
+	}
+	if qm.EndpointID == "" || qm.ApplianceID == "" || qm.ServiceURI == "" || qm.DataPoint == "" {
+		return ErrMissingQueryFields
+	}
+	return nil
+}
+
+func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
+	if err := d.getTokenIfNeeded(ctx); err != nil {
+		return backend.ErrDataResponse(tokenErrorStatus(err), "Token error: "+err.Error())
+	}
+	var response backend.DataResponse
+
+	// Unmarshal the JSON into our query model (only for endpoint/appliance/service/datapoint)
+	if body := bytes.TrimSpace(query.JSON); len(body) == 0 || bytes.Equal(body, []byte("null")) {
+		var res backend.DataResponse
+		appendResponseNotice(&res, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     "Empty query: select an endpoint, appliance, service and data point",
+		})
+		return res
+	}
+	var qm WEMSQueryModel
+	if err := json.Unmarshal(query.JSON, &qm); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	// Substitute dashboard variables that reached us uninterpolated
+	interpolateQueryModel(&qm)
+
+	if d.settings.PerEndpointTokens {
+		if endpointID := strings.TrimSpace(qm.EndpointID); endpointID != "" {
+			token, err := d.endpointToken(ctx, endpointID)
+			if err != nil {
+				return backend.ErrDataResponse(tokenErrorStatus(err), "Token error: "+err.Error())
+			}
+			ctx = withBearer(ctx, token)
+		}
+	}
+
+	// Annotation queries only need the endpoint
+	if isAnnotationQuery(query) {
+		qm.EndpointID = strings.TrimSpace(qm.EndpointID)
+		qm.ApplianceID = strings.TrimSpace(qm.ApplianceID)
+		if qm.EndpointID == "" {
+			return backend.ErrDataResponse(backend.StatusBadRequest, ErrMissingQueryFields.Error())
+		}
+		frame, err := d.annotationFrame(ctx, qm, query)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		}
+		response.Frames = append(response.Frames, frame)
+		return response
+	}
+
+	if isFleetStatusQuery(query) {
+		frame, err := d.fleetStatusFrame(ctx, qm.EndpointIDs)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		}
+		response.Frames = append(response.Frames, frame)
+		return response
+	}
+
+	// Validate required fields
+	if err := validateQueryModel(&qm); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if qm.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = withQueryTimeout(ctx, time.Duration(qm.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	frames, err := d.queryFrames(ctx, qm, query)
+	switch {
+	case err == nil && d.settings.ServeStaleOnError:
+		d.rememberFrames(qm, query.TimeRange, frames)
+	case err != nil:
+		stale, ok := d.staleFrames(qm, query.TimeRange, err)
+		if !d.settings.ServeStaleOnError || !ok {
+			return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		}
+		frames = stale
+	}
+	if d.settings.ProcessLabels {
+		d.addProcessLabels(ctx, qm, frames)
+	}
+	if d.settings.ModelLabels {
+		d.addModelLabels(ctx, qm, frames)
+	}
+	if d.settings.FrameFormat == FrameFormatWide {
+		series, summaries := splitSummaries(frames)
+		tolerance, _ := parseJoinTolerance(qm)
+		wide, err := joinWide(series, tolerance)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		}
+		frames = append([]*data.Frame{wide}, summaries...)
+	}
+	response.Frames = append(response.Frames, frames...)
+	return response
+}
+
+// queryFrames fetches the data requested by a validated query model.
+func (d *Datasource) queryFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	if qm.Mode == QueryModeCurrent {
+		frame, err := d.currentFrame(ctx, qm)
+		if err != nil {
+			return nil, err
+		}
+		return []*data.Frame{frame}, nil
+	}
+
+	if len(qm.ApplianceIDs) > 0 {
+		return d.applianceFrames(ctx, qm, query)
+	}
+
+	if qm.Numerator != nil {
+		frame, err := d.ratioFrame(ctx, qm, query)
+		if err != nil {
+			return nil, err
+		}
+		return []*data.Frame{frame}, nil
+	}
+
+	if len(qm.Targets) > 0 {
+		return d.targetFrames(ctx, qm, query)
+	}
+
+	if functions := aggregateFunctions(qm); qm.SplitAggregates && len(functions) > 1 {
+		return d.aggregateFrames(ctx, qm, query, functions)
+	}
+
+	if qm.Histogram {
+		return d.histogramFrames(ctx, qm, query)
+	}
+
+	if qm.MultiResolution {
+		return d.multiResolutionFrames(ctx, qm, query)
+	}
+
+	if qm.SummaryStats {
+		return d.summaryFrames(ctx, qm, query)
+	}
+
+	frame, err := d.seriesFrame(ctx, qm, query)
+	if err != nil {
+		return nil, err
+	}
+	return []*data.Frame{frame}, nil
+}
+
+// seriesFrame fetches the series described by qm over the range of query and
+// converts it into a data frame.
+func (d *Datasource) seriesFrame(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) (*data.Frame, error) {
+	points, notices, err := d.seriesPoints(ctx, qm, query)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := d.pointsFrame(qm, points)
+	if err != nil {
+		return nil, err
+	}
+	if len(notices) > 0 {
+		frame.AppendNotices(notices...)
+	}
+	return frame, nil
+}
+
+// seriesPoints fetches the points of the series described by qm over the
+// range of query, dropping points without a time and applying the point cap,
+// resampling, calendar bucketing and decimation. Warnings to attach to the
+// resulting frame are returned if points were dropped or the series was or
+// may have been truncated.
+func (d *Datasource) seriesPoints(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]TimeSeriesDataPoint, []data.Notice, error) {
+	step, _ := parseResampleStep(qm)
+	if step > 0 {
+		if err := checkResampleSlots(query.TimeRange.From, query.TimeRange.To, step, d.maxPoints()); err != nil {
+			return nil, nil, err
+		}
+	}
+	points, err := d.fetchSeries(ctx, d.seriesPath(qm, query))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && d.statusAction(apiErr.StatusCode) == StatusActionEmpty {
+		points, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var notices []data.Notice
+	if notice := d.limitNotice(len(points), d.seriesLimit(query)); notice != nil {
+		notices = append(notices, *notice)
+	}
+	if n := countZeroTimes(points); n > 0 {
+		points = slices.DeleteFunc(slices.Clone(points), func(p TimeSeriesDataPoint) bool { return p.Time <= 0 })
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Dropped %d points without a timestamp", n),
+		})
+	}
+	points, notice, err := d.limitPoints(points)
+	if err != nil {
+		return nil, nil, err
+	}
+	if notice != nil {
+		notices = append(notices, *notice)
+	}
+	if step > 0 {
+		points = resamplePoints(points, query.TimeRange.From, query.TimeRange.To, step, qm.FillMode, qm.BucketReducer, d.convertOptions())
+	}
+	if qm.CalendarBucket != "" {
+		points = calendarPoints(points, qm.CalendarBucket, d.calendarLocation(qm), calendarReducer(qm, d.aggregateFunction(qm)), d.convertOptions())
+	}
+	if qm.LTTB && query.MaxDataPoints > 0 {
+		points = lttbPoints(points, int(query.MaxDataPoints), d.convertOptions())
+	}
+	return points, notices, nil
+}
+
+// countZeroTimes counts the points with a missing or zero time, which would
+// otherwise be plotted at the Unix epoch.
+func countZeroTimes(points []TimeSeriesDataPoint) int {
+	n := 0
+	for _, p := range points {
+		if p.Time <= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// pointsFrame converts WEMS points into a data frame, applying the
+// post-processing options of qm.
+func (d *Datasource) pointsFrame(qm WEMSQueryModel, points []TimeSeriesDataPoint) (*data.Frame, error) {
+	if qm.DedupeTimestamps {
+		reducer := qm.BucketReducer
+		if reducer == "" && qm.DedupeKeep == "first" {
+			reducer = BucketReducerFirst
+		}
+		points = dedupePoints(points, reducer, d.convertOptions())
+	}
+
+	label := d.seriesName(qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
+
+	// Convert to Grafana data frame
+	var times []time.Time
+	var valueField *data.Field
+	var coerced bool
+	mixed := valueKinds(points)
+	switch {
+	case qm.Mode == QueryModeRaw:
+		var values []string
+		times, values = stringPoints(points)
+		valueField = data.NewField(label, nil, values)
+	case len(mixed) > 1 && d.settings.OnMixedType == MixedTypeError:
+		return nil, fmt.Errorf("series mixes value types: %s", strings.Join(mixed, ", "))
+	case len(mixed) > 1 && d.settings.OnMixedType == MixedTypeString:
+		var values []string
+		times, values = stringPoints(points)
+		valueField = data.NewField(label, nil, values)
+	case qm.ClampMin == nil && qm.ClampMax == nil && needsInt64(points):
+		var values []int64
+		times, values = intPoints(points)
+		valueField = data.NewField(label, nil, values)
+	default:
+		var values []float64
+		var err error
+		opts := d.convertOptions()
+		times, values, err = convertPoints(points, opts)
+		if err != nil {
+			return nil, err
+		}
+		coerced = d.settings.WarnOnAllZeroCoerced && allCoerced(points, opts)
+		if qm.Precision != nil && *qm.Precision >= 0 {
+			roundValues(values, *qm.Precision)
+		}
+		dropped := clampValues(values, qm)
+		if !slices.ContainsFunc(values, isNonFinite) && dropped == nil {
+			valueField = data.NewField(label, nil, values)
+		} else {
+			nullable := finiteValues(values)
+			for j, drop := range dropped {
+				if drop {
+					nullable[j] = nil
+				}
+			}
+			valueField = data.NewField(label, nil, nullable)
+		}
+	}
+
+	if unit := d.unitFor(qm); unit != "" {
+		valueField.Config = &data.FieldConfig{Unit: unit}
+	}
+	if len(qm.ValidValues) > 0 {
+		// Build a ValueMapper (map[string]ValueMappingResult) for enum value mappings
+		mapper := data.ValueMapper{}
+		for i, val := range qm.ValidValues {
+			mapper[fmt.Sprintf("%d", i)] = data.ValueMappingResult{
+				Text:  val,
+				Index: i,
+			}
+		}
+		valueMappings := data.ValueMappings{mapper}
+		if valueField.Config == nil {
+			valueField.Config = &data.FieldConfig{}
+		}
+		valueField.Config.Mappings = valueMappings
+	}
+	if qm.DisplayDecimals != nil && *qm.DisplayDecimals >= 0 {
+		if valueField.Config == nil {
+			valueField.Config = &data.FieldConfig{}
+		}
+		decimals := uint16(min(*qm.DisplayDecimals, math.MaxUint16))
+		valueField.Config.Decimals = &decimals
+	}
+	if qm.DisplayName != "" || qm.Color != "" {
+		if valueField.Config == nil {
+			valueField.Config = &data.FieldConfig{}
+		}
+		valueField.Config.DisplayNameFromDS = qm.DisplayName
+		if qm.Color != "" {
+			valueField.Config.Color = map[string]interface{}{
+				"mode":       "fixed",
+				"fixedColor": qm.Color,
+			}
+		}
+	}
+	frame := data.NewFrame(label,
+		data.NewField("time", nil, times),
+		valueField,
+	)
+	if field := interpolatedField(points); field != nil {
+		frame.Fields = append(frame.Fields, field)
+	}
+	if coerced {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "No value could be converted to a number, all values are shown as 0; check the datapoint type",
+		})
+	}
+	if qm.StaleAfterSeconds > 0 {
+		if notice, stale := staleNotice(times, time.Duration(qm.StaleAfterSeconds)*time.Second, time.Now()); stale {
+			frame.AppendNotices(notice)
+		}
 	}
+	return frame, nil
+}
 
-	return response, nil
+// staleNotice reports whether the newest of times is older than threshold at
+// now and returns the warning to attach in that case. An empty series is not
+// considered stale.
+func staleNotice(times []time.Time, threshold time.Duration, now time.Time) (data.Notice, bool) {
+	if len(times) == 0 {
+		return data.Notice{}, false
+	}
+	newest := times[0]
+	for _, t := range times[1:] {
+		if t.After(newest) {
+			newest = t
+		}
+	}
+	if age := now.Sub(newest); age > threshold {
+		return data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Data is stale: latest point is from %s (%s ago)", newest.UTC().Format(time.RFC3339), age.Truncate(time.Second)),
+		}, true
+	}
+	return data.Notice{}, false
 }
 
-type WEMSQueryModel struct {
-	EndpointID        string   `json:"endpoint_id"`
-	ApplianceID       string   `json:"appliance_id"`
-	ServiceURI        string   `json:"service_uri"`
-	DataPoint         string   `json:"data_point"`
-	AggregateFunction string   `json:"aggregate_function,omitempty"`
-	CreateEmptyValues *bool    `json:"create_empty_values,omitempty"`
-	Unit              string   `json:"unit,omitempty"`
-	ValidValues       []string `json:"validValues,omitempty"`
+// APIError is returned when WEMS answers a request with a non-200 status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RequestID  string
 }
 
-type TimeSeriesDataPoint struct {
-	Time  int64       `json:"time"`
-	Value interface{} `json:"value"`
+func (e *APIError) Error() string {
+	return fmt.Sprintf("WEMS API error: %s %s (request ID %s)", e.Status, e.Body, e.RequestID)
 }
 
-func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
-	if err := d.getTokenIfNeeded(ctx); err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, "Token error: "+err.Error())
+// delayedTo returns the end of tr, moved back to QueryDelaySeconds before now
+// if it is later than that, but not before the start of tr.
+func (d *Datasource) delayedTo(tr backend.TimeRange) time.Time {
+	if d.settings.QueryDelaySeconds <= 0 {
+		return tr.To
 	}
-	var response backend.DataResponse
+	limit := time.Now().Add(-time.Duration(d.settings.QueryDelaySeconds) * time.Second)
+	switch {
+	case tr.To.Before(limit):
+		return tr.To
+	case limit.Before(tr.From):
+		return tr.From
+	default:
+		return limit
+	}
+}
 
-	// Unmarshal the JSON into our query model (only for endpoint/appliance/service/datapoint)
-	var qm WEMSQueryModel
-	if err := json.Unmarshal(query.JSON, &qm); err != nil {
-		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
+// seriesLimit returns the series limit sent for query, or 0 if none is sent.
+func (d *Datasource) seriesLimit(query backend.DataQuery) int {
+	if query.MaxDataPoints > 0 {
+		return 10000 //TODO use query.MaxDataPoints
 	}
+	return d.settings.DefaultLimit
+}
 
-	// Validate required fields
-	if qm.EndpointID == "" || qm.ApplianceID == "" || qm.ServiceURI == "" || qm.DataPoint == "" {
-		return backend.ErrDataResponse(backend.StatusBadRequest, "Missing required query fields: endpoint_id, appliance_id, service_uri, data_point")
+// aggregateFunction returns the aggregate function of qm, falling back to
+// DefaultAggregateFunction.
+func (d *Datasource) aggregateFunction(qm WEMSQueryModel) string {
+	if qm.AggregateFunction != "" {
+		return qm.AggregateFunction
 	}
+	return d.settings.DefaultAggregateFunction
+}
 
+// seriesPath builds the WEMS series path for qm, relative to the base URL and
+// including the time range and aggregation parameters taken from query.
+func (d *Datasource) seriesPath(qm WEMSQueryModel, query backend.DataQuery) string {
 	// Build the WEMS API URL
-	url := fmt.Sprintf("%s/v1/endpoint/%s/series/%s/%s/%s", d.baseURL, qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
+	path := fmt.Sprintf("/v1/endpoint/%s/series/%s/%s/%s", qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
 
 	// Build query params using backend.DataQuery fields
 	params := make(map[string]string)
 	params["from"] = fmt.Sprintf("%d", query.TimeRange.From.Unix())
-	params["to"] = fmt.Sprintf("%d", query.TimeRange.To.Unix())
-	if query.MaxDataPoints > 0 {
-		params["limit"] = "10000" //TODO use query.MaxDataPoints
+	params["to"] = fmt.Sprintf("%d", d.delayedTo(query.TimeRange).Unix())
+	if limit := d.seriesLimit(query); limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
 	}
-	if query.Interval > 0 {
-		params["aggregateInterval"] = fmt.Sprintf("%ds", int(query.Interval.Seconds()))
+	aggregate := d.aggregateFunction(qm)
+	if query.Interval > 0 && aggregate != AggregateNone {
+		interval := min(max(query.Interval, d.minInterval), maxAggregateInterval)
+		if qm.CalendarBucket != "" {
+			interval = calendarInterval(interval, calendarAggregateInterval(d.calendarLocation(qm), query.TimeRange))
+		}
+		params["aggregateInterval"] = fmt.Sprintf("%ds", int(interval.Seconds()))
 	}
-	if qm.AggregateFunction != "" {
-		params["aggregateFunction"] = qm.AggregateFunction
+	if aggregate != "" && aggregate != AggregateNone {
+		params["aggregateFunction"] = aggregate
 	}
 	if qm.CreateEmptyValues != nil {
 		params["createEmptyValues"] = fmt.Sprintf("%v", *qm.CreateEmptyValues)
 	}
+	if d.settings.Timezone != "" {
+		params["timezone"] = url.QueryEscape(d.settings.Timezone)
+	}
 
-	// Build the full URL with query params
+	// Build the full URL with query params, in a stable order so identical
+	// queries produce identical URLs
 	qstr := ""
-	for k, v := range params {
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		v := params[k]
 		if qstr == "" {
 			qstr = "?"
 		} else {
@@ -227,90 +1387,245 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 		}
 		qstr += fmt.Sprintf("%s=%s", k, v)
 	}
-	fullURL := url + qstr
+	return path + qstr
+}
+
+// fetchSeries requests path from WEMS and decodes the returned points.
+// Concurrent calls for the same path and query timeout share a single
+// upstream request, so the returned points must not be modified. The request
+// runs detached from the callers, each of which stops waiting when its own
+// ctx is done, and its retries are charged to the retry budget of the caller
+// that started it.
+func (d *Datasource) fetchSeries(ctx context.Context, path string) ([]TimeSeriesDataPoint, error) {
+	timeout := queryTimeout(ctx)
+	key := fmt.Sprintf("%s|%s", path, timeout)
+	ch := d.seriesGroup.DoChan(key, func() (interface{}, error) {
+		flightCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return d.requestSeries(flightCtx, path)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]TimeSeriesDataPoint), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	// Prepare HTTP request
-	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
-	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, "Failed to create request: "+err.Error())
+// requestSeries performs the upstream requests for fetchSeries. Paginated
+// responses are followed by their cursor until the last page, at most
+// maxSeriesPages pages.
+func (d *Datasource) requestSeries(ctx context.Context, path string) ([]TimeSeriesDataPoint, error) {
+	var points []TimeSeriesDataPoint
+	cursor := ""
+	for page := 0; ; page++ {
+		if page == maxSeriesPages {
+			return nil, fmt.Errorf("%w: more than %d pages", ErrTooManyPages, maxSeriesPages)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pagePoints, next, err := d.requestSeriesPage(ctx, cursorPath(path, cursor))
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, pagePoints...)
+		if next == "" {
+			return points, nil
+		}
+		cursor = next
 	}
-	req.Header.Set("Authorization", "Bearer "+d.token)
-	req.Header.Set("Accept", "application/json")
+}
+
+// requestSeriesPage requests one page of a series, repeating the request
+// once if the response was truncated and RetryTruncated is set.
+func (d *Datasource) requestSeriesPage(ctx context.Context, path string) ([]TimeSeriesDataPoint, string, error) {
+	points, cursor, err := d.requestSeriesOnce(ctx, path)
+	if errors.Is(err, ErrResponseTruncated) && d.settings.RetryTruncated && retryBudgetOf(ctx).take() {
+		points, cursor, err = d.requestSeriesOnce(ctx, path)
+	}
+	return points, cursor, err
+}
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
+// requestSeriesOnce makes a single series request, apart from the retries of
+// sendSeriesRequest, and returns its points and next page cursor.
+func (d *Datasource) requestSeriesOnce(ctx context.Context, path string) ([]TimeSeriesDataPoint, string, error) {
+	client := d.httpClient(queryTimeout(ctx))
+	trace := seriesTraceOf(ctx)
+	resp, err := d.sendSeriesRequest(ctx, client, func(baseURL string) (*http.Request, error) {
+		trace.request(baseURL + path)
+		// Prepare HTTP request
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+d.bearer(ctx))
+		req.Header.Set("Accept", d.seriesAccept())
+		return req, nil
+	})
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, "Request failed: "+err.Error())
+		return nil, "", fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	trace.response(resp)
 	if resp.StatusCode != 200 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("WEMS API error: %s %s", resp.Status, string(bodyBytes)))
+		return nil, "", &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(bodyBytes), RequestID: requestIDOf(resp)}
 	}
 
-	var points []TimeSeriesDataPoint
-	if err := json.NewDecoder(resp.Body).Decode(&points); err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, "Failed to decode WEMS response: "+err.Error())
+	resp.Body = capBody(resp.Body, d.maxResponseBytes())
+	points, cursor, err := decodeSeriesPage(resp)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode WEMS response: %w", err)
 	}
+	return points, cursor, nil
+}
 
-	// Convert to Grafana data frame
+// convertOptions controls how convertPoints turns WEMS values into numbers.
+type convertOptions struct {
+	decimalSeparator string
+	groupSeparator   string
+	trueValue        float64
+	falseValue       float64
+	// nonFinite is the OnNonFinite policy.
+	nonFinite string
+}
+
+func (d *Datasource) convertOptions() convertOptions {
+	opts := convertOptions{
+		decimalSeparator: d.settings.DecimalSeparator,
+		groupSeparator:   d.settings.GroupSeparator,
+		trueValue:        1,
+		nonFinite:        d.settings.OnNonFinite,
+	}
+	if v := d.settings.BoolMapping.TrueValue; v != nil {
+		opts.trueValue = *v
+	}
+	if v := d.settings.BoolMapping.FalseValue; v != nil {
+		opts.falseValue = *v
+	}
+	return opts
+}
+
+// parseNumber parses a numeric string formatted with the configured
+// separators.
+func (o convertOptions) parseNumber(v string) (float64, error) {
+	if o.groupSeparator != "" {
+		v = strings.ReplaceAll(v, o.groupSeparator, "")
+	}
+	if o.decimalSeparator != "" && o.decimalSeparator != "." {
+		v = strings.ReplaceAll(v, o.decimalSeparator, ".")
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// convertPoints splits WEMS points into frame columns, converting each value
+// to float64. Values that cannot be converted become 0. Times are in UTC, so
+// frames do not depend on the zone of the Grafana server. NaN and infinite
+// values are kept for the caller to treat as null, unless the NonFiniteError
+// policy of opts fails the conversion.
+func convertPoints(points []TimeSeriesDataPoint, opts convertOptions) ([]time.Time, []float64, error) {
 	times := make([]time.Time, 0, len(points))
 	values := make([]float64, 0, len(points))
 	for _, p := range points {
-		times = append(times, time.Unix(p.Time, 0))
-		// Try to convert value to float64
-		switch v := p.Value.(type) {
-		case float64:
-			values = append(values, v)
-		case int:
-			values = append(values, float64(v))
-		case int64:
-			values = append(values, float64(v))
-		case bool:
-			if v {
-				values = append(values, 1.0)
-			} else {
-				values = append(values, 0.0)
-			}
-		case string:
-			// Try to parse string as float
-			f, err := strconv.ParseFloat(v, 64)
-			if err == nil {
-				values = append(values, f)
-			} else {
-				values = append(values, 0)
-			}
-		default:
-			values = append(values, 0)
+		t := time.Unix(p.Time, 0).UTC()
+		f, _ := convertValue(p.Value, opts)
+		if err := opts.checkFinite(f, t); err != nil {
+			return nil, nil, err
 		}
+		times = append(times, t)
+		values = append(values, f)
 	}
+	return times, values, nil
+}
 
-	label := fmt.Sprintf("%s/%s/%s/%s", qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
-	valueField := data.NewField(label, nil, values)
-	if qm.Unit != "" {
-		valueField.Config = &data.FieldConfig{Unit: qm.Unit}
+// checkFinite applies the OnNonFinite policy of o to the value v at t: it
+// fails under NonFiniteError if v is NaN or infinite.
+func (o convertOptions) checkFinite(v float64, t time.Time) error {
+	if o.nonFinite == NonFiniteError && isNonFinite(v) {
+		return fmt.Errorf("series contains non-finite value %v at %s", v, t.Format(time.RFC3339))
 	}
-	if len(qm.ValidValues) > 0 {
-		// Build a ValueMapper (map[string]ValueMappingResult) for enum value mappings
-		mapper := data.ValueMapper{}
-		for i, val := range qm.ValidValues {
-			mapper[fmt.Sprintf("%d", i)] = data.ValueMappingResult{
-				Text:  val,
-				Index: i,
-			}
+	return nil
+}
+
+// convertValue converts a WEMS value to float64. It reports false if the
+// value could not be converted and 0 was used instead; null is not reported
+// as a failure. Integers beyond maxExactInt are rounded to the nearest
+// float64; pointsFrame keeps such series as int64 with intPoints.
+func convertValue(value interface{}, opts convertOptions) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return opts.trueValue, true
 		}
-		valueMappings := data.ValueMappings{mapper}
-		if valueField.Config == nil {
-			valueField.Config = &data.FieldConfig{}
+		return opts.falseValue, true
+	case string:
+		// Try to parse string as float
+		f, err := opts.parseNumber(v)
+		if err != nil {
+			return 0, false
 		}
-		valueField.Config.Mappings = valueMappings
+		return f, true
+	case nil:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// allCoerced reports whether every non-null value of points failed to convert
+// and was replaced by 0, which usually hints at a type mismatch.
+func allCoerced(points []TimeSeriesDataPoint, opts convertOptions) bool {
+	coerced := 0
+	for _, p := range points {
+		if p.Value == nil {
+			continue
+		}
+		if _, ok := convertValue(p.Value, opts); ok {
+			return false
+		}
+		coerced++
+	}
+	return coerced > 0
+}
+
+// dedupePoints returns points with at most one point per timestamp. The
+// position of the first occurrence is kept; the points sharing a timestamp
+// are combined by reduceBucket with reducer.
+func dedupePoints(points []TimeSeriesDataPoint, reducer string, opts convertOptions) []TimeSeriesDataPoint {
+	index := make(map[int64]int, len(points))
+	var buckets [][]TimeSeriesDataPoint
+	for _, p := range points {
+		if i, ok := index[p.Time]; ok {
+			buckets[i] = append(buckets[i], p)
+			continue
+		}
+		index[p.Time] = len(buckets)
+		buckets = append(buckets, []TimeSeriesDataPoint{p})
+	}
+	result := make([]TimeSeriesDataPoint, len(buckets))
+	for i, bucket := range buckets {
+		result[i] = reduceBucket(bucket, reducer, opts)
+		result[i].Time = bucket[0].Time
+	}
+	return result
+}
+
+// roundValues rounds each value in place to the given number of decimals.
+func roundValues(values []float64, precision int) {
+	scale := math.Pow(10, float64(precision))
+	for i, v := range values {
+		values[i] = math.Round(v*scale) / scale
 	}
-	frame := data.NewFrame(label,
-		data.NewField("time", nil, times),
-		valueField,
-	)
-	response.Frames = append(response.Frames, frame)
-	return response
 }
 
 // CheckHealth handles health checks sent from Grafana to the plugin.
@@ -318,20 +1633,62 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 // datasource configuration page which allows users to verify that
 // a datasource is working as expected.
 func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.healthCheckTimeout())
+	defer cancel()
+	if err := validateSettings(d.settings); err != nil {
+		return &backend.CheckHealthResult{
+			Status:      backend.HealthStatusError,
+			Message:     "Invalid settings: " + err.Error(),
+			JSONDetails: d.healthDetails(ctx, err),
+		}, nil
+	}
 	if err := d.getTokenIfNeeded(ctx); err != nil {
+		// With HealthCheckSoftFail an unreachable WEMS is reported without
+		// failing provisioning; rejected credentials still fail
+		if d.settings.HealthCheckSoftFail && errors.Is(err, ErrTokenNetwork) {
+			return &backend.CheckHealthResult{
+				Status:      backend.HealthStatusOk,
+				Message:     "Warning: " + tokenHealthMessage(err),
+				JSONDetails: d.healthDetails(ctx, err),
+			}, nil
+		}
 		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: "Token error: " + err.Error(),
+			Status:      backend.HealthStatusError,
+			Message:     tokenHealthMessage(err),
+			JSONDetails: d.healthDetails(ctx, err),
 		}, nil
 	}
 	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Data source is working",
+		Status:      backend.HealthStatusOk,
+		Message:     "Data source is working",
+		JSONDetails: d.healthDetails(ctx, nil),
 	}, nil
 }
 
+// defaultHealthCheckTimeout bounds health checks without a configured
+// HealthCheckTimeoutSeconds.
+const defaultHealthCheckTimeout = 10 * time.Second
+
+func (d *Datasource) healthCheckTimeout() time.Duration {
+	if d.settings.HealthCheckTimeoutSeconds > 0 {
+		return time.Duration(d.settings.HealthCheckTimeoutSeconds) * time.Second
+	}
+	return defaultHealthCheckTimeout
+}
+
 // CallResource handles resource calls from the frontend (e.g., /resources/endpoint-list, /resources/appliance-list)
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if methods, ok := resourceMethods[req.Path]; ok && !slices.Contains(methods, req.Method) {
+		return sender.Send(&backend.CallResourceResponse{
+			Status:  http.StatusMethodNotAllowed,
+			Headers: map[string][]string{"Allow": {strings.Join(methods, ", ")}},
+			Body:    []byte("Method not allowed"),
+		})
+	}
+	// The configuration is served even while WEMS is unreachable
+	if req.Path == "config" {
+		return d.configResource(sender)
+	}
 	if err := d.getTokenIfNeeded(ctx); err != nil {
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusInternalServerError,
@@ -339,43 +1696,15 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 		})
 	}
 	if req.Path == "endpoint-list" {
-		// Build WEMS endpoint list URL
-		url := d.baseURL + "/v1/endpoint/"
-		request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to create request: " + err.Error()),
-			})
-		}
-		request.Header.Set("Authorization", "Bearer "+d.token)
-		request.Header.Set("Accept", "application/json")
-
-		client := &http.Client{Timeout: 20 * time.Second}
-		resp, err := client.Do(request)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Request failed: " + err.Error()),
-			})
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to read response: " + err.Error()),
-			})
+		if parsedUrl, err := url.Parse(req.URL); err == nil && (parsedUrl.Query().Has("page") || parsedUrl.Query().Has("pageSize")) {
+			return d.endpointPage(ctx, parsedUrl.Query(), sender)
 		}
-
-		if resp.StatusCode != 200 {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: resp.StatusCode,
-				Body:   body,
-			})
+		// Build WEMS endpoint list URL
+		url := d.currentBaseURL() + "/v1/endpoint/"
+		body, errResp := d.prefetchedResourceBody(ctx, url)
+		if errResp != nil {
+			return sender.Send(errResp)
 		}
-
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusOK,
 			Body:   body,
@@ -384,9 +1713,17 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 
 	if req.Path == "appliance-list" {
 		endpointId := ""
+		draft := "false"
+		includeConfig := "false"
 		if req.URL != "" {
 			if parsedUrl, err := url.Parse(req.URL); err == nil {
 				endpointId = parsedUrl.Query().Get("endpointId")
+				if v := parsedUrl.Query().Get("draft"); v != "" {
+					draft = v
+				}
+				if v := parsedUrl.Query().Get("includeApplianceConfiguration"); v != "" {
+					includeConfig = v
+				}
 			}
 		}
 		if endpointId == "" {
@@ -395,106 +1732,46 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 				Body:   []byte("Missing endpointId parameter"),
 			})
 		}
-		url := fmt.Sprintf("%s/v1/endpoint/%s/description?includeApplianceConfiguration=false&draft=false", d.baseURL, endpointId)
-		req2, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
+		if _, err := strconv.ParseBool(draft); err != nil {
 			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to create request: " + err.Error()),
+				Status: http.StatusBadRequest,
+				Body:   []byte("Invalid draft parameter"),
 			})
 		}
-		req2.Header.Set("Authorization", "Bearer "+d.token)
-		req2.Header.Set("Accept", "application/json")
-		client := &http.Client{Timeout: 20 * time.Second}
-		resp, err := client.Do(req2)
-		if err != nil {
+		if _, err := strconv.ParseBool(includeConfig); err != nil {
 			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Request failed: " + err.Error()),
+				Status: http.StatusBadRequest,
+				Body:   []byte("Invalid includeApplianceConfiguration parameter"),
 			})
 		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
+		url := d.endpointDescriptionURL(endpointId, includeConfig, draft)
+		body, errResp := d.prefetchedResourceBody(ctx, url)
+		if errResp != nil {
+			return sender.Send(errResp)
+		}
+		// Parse and flatten appliances from processes or the top level
+		var desc endpointDescription
+		if err := json.Unmarshal(body, &desc); err != nil {
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to read response: " + err.Error()),
+				Body:   []byte("Failed to parse appliances: " + err.Error()),
 			})
 		}
-		if resp.StatusCode != 200 {
+		items, err := desc.applianceItems()
+		if err != nil {
 			return sender.Send(&backend.CallResourceResponse{
-				Status: resp.StatusCode,
-				Body:   body,
+				Status: http.StatusBadGateway,
+				Body:   []byte("Failed to parse appliances: " + err.Error()),
 			})
 		}
-		// Parse and flatten appliances from processes
-		type appliance struct {
-			ID                 string `json:"id"`
-			FriendlyName       string `json:"friendlyName"`
-			ApplianceReference int    `json:"applianceReference"`
-		}
-		type process struct {
-			ID         string      `json:"id"`
-			Name       string      `json:"name"`
-			Appliances []appliance `json:"appliances"`
-		}
-		type descResp struct {
-			Processes []process `json:"processes"`
-		}
-		var desc descResp
-		if err := json.Unmarshal(body, &desc); err != nil {
+		// Fetch model info for each appliance in parallel
+		result, err := collectAppliances(ctx, items, d.modelLookupConcurrency(), d.applianceOption)
+		if err != nil {
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to parse appliances: " + err.Error()),
+				Body:   []byte("Failed to list appliances: " + err.Error()),
 			})
 		}
-		// Fetch model info for each appliance in parallel
-		result := make([]map[string]string, 0)
-		type modelInfo struct {
-			FriendlyName string `json:"friendlyName"`
-		}
-		ch := make(chan map[string]string, 32)
-		count := 0
-		for _, proc := range desc.Processes {
-			for _, app := range proc.Appliances {
-				count++
-				go func(app appliance, procName string) {
-					label := app.FriendlyName
-					if label == "" {
-						label = app.ID
-					}
-					if procName != "" {
-						label = fmt.Sprintf("[%s] %s", procName, label)
-					}
-					modelLabel := ""
-					if app.ApplianceReference != 0 {
-						modelUrl := fmt.Sprintf("%s/v1/component/appliance/%d", d.baseURL, app.ApplianceReference)
-						reqModel, err := http.NewRequestWithContext(ctx, "GET", modelUrl, nil)
-						if err == nil {
-							reqModel.Header.Set("Authorization", "Bearer "+d.token)
-							reqModel.Header.Set("Accept", "application/json")
-							client := &http.Client{Timeout: 10 * time.Second}
-							respModel, err := client.Do(reqModel)
-							if err == nil && respModel.StatusCode == 200 {
-								defer respModel.Body.Close()
-								var model modelInfo
-								if err := json.NewDecoder(respModel.Body).Decode(&model); err == nil && model.FriendlyName != "" {
-									modelLabel = model.FriendlyName
-								}
-							}
-						}
-					}
-					if modelLabel != "" {
-						label = fmt.Sprintf("%s (%s)", label, modelLabel)
-					}
-					ch <- map[string]string{"id": app.ID, "label": label}
-				}(app, proc.Name)
-			}
-		}
-		for i := 0; i < count; i++ {
-			item := <-ch
-			result = append(result, item)
-		}
 		respBytes, _ := json.Marshal(result)
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusOK,
@@ -517,40 +1794,13 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 				Body:   []byte("Missing endpointId or applianceId parameter"),
 			})
 		}
-		url := fmt.Sprintf("%s/v1/endpoint/%s/values/%s", d.baseURL, endpointId, applianceId)
-		req2, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to create request: " + err.Error()),
-			})
-		}
-		req2.Header.Set("Authorization", "Bearer "+d.token)
-		req2.Header.Set("Accept", "application/json")
-		client := &http.Client{Timeout: 20 * time.Second}
-		resp, err := client.Do(req2)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Request failed: " + err.Error()),
-			})
-		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to read response: " + err.Error()),
-			})
-		}
-		if resp.StatusCode != 200 {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: resp.StatusCode,
-				Body:   body,
-			})
+		url := fmt.Sprintf("%s/v1/endpoint/%s/values/%s", d.currentBaseURL(), endpointId, applianceId)
+		body, errResp := d.getResourceBody(ctx, url)
+		if errResp != nil {
+			return sender.Send(errResp)
 		}
 		// Parse JSON keys as service URIs
-		var raw map[string]interface{}
+		var raw map[string]json.RawMessage
 		if err := json.Unmarshal(body, &raw); err != nil {
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
@@ -558,10 +1808,10 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 			})
 		}
 		var result []map[string]string
-		for k := range raw {
+		for k, v := range raw {
 			result = append(result, map[string]string{
 				"uri":   k,
-				"label": k,
+				"label": serviceLabel(k, v),
 			})
 		}
 		respBytes, _ := json.Marshal(result)
@@ -572,58 +1822,7 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 	}
 
 	if req.Path == "datapoint-list" {
-		endpointId := ""
-		applianceId := ""
-		serviceUri := ""
-		if req.URL != "" {
-			if parsedUrl, err := url.Parse(req.URL); err == nil {
-				endpointId = parsedUrl.Query().Get("endpointId")
-				applianceId = parsedUrl.Query().Get("applianceId")
-				serviceUri = parsedUrl.Query().Get("serviceUri")
-			}
-		}
-		if endpointId == "" || applianceId == "" || serviceUri == "" {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusBadRequest,
-				Body:   []byte("Missing endpointId, applianceId, or serviceUri parameter"),
-			})
-		}
-		url := fmt.Sprintf("%s/v1/endpoint/%s/values/%s/%s", d.baseURL, endpointId, applianceId, serviceUri)
-		req2, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to create request: " + err.Error()),
-			})
-		}
-		req2.Header.Set("Authorization", "Bearer "+d.token)
-		req2.Header.Set("Accept", "application/json")
-		client := &http.Client{Timeout: 20 * time.Second}
-		resp, err := client.Do(req2)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Request failed: " + err.Error()),
-			})
-		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to read response: " + err.Error()),
-			})
-		}
-		if resp.StatusCode != 200 {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: resp.StatusCode,
-				Body:   body,
-			})
-		}
-		return sender.Send(&backend.CallResourceResponse{
-			Status: http.StatusOK,
-			Body:   body,
-		})
+		return d.datapointList(ctx, req, sender)
 	}
 
 	if req.Path == "datapoint-unit" {
@@ -645,37 +1844,10 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 				Body:   []byte("Missing endpointId, applianceId, serviceUri, or datapoint parameter"),
 			})
 		}
-		url := fmt.Sprintf("%s/v1/endpoint/%s/values/%s/%s", d.baseURL, endpointId, applianceId, serviceUri)
-		req2, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to create request: " + err.Error()),
-			})
-		}
-		req2.Header.Set("Authorization", "Bearer "+d.token)
-		req2.Header.Set("Accept", "application/json")
-		client := &http.Client{Timeout: 20 * time.Second}
-		resp, err := client.Do(req2)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Request failed: " + err.Error()),
-			})
-		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusInternalServerError,
-				Body:   []byte("Failed to read response: " + err.Error()),
-			})
-		}
-		if resp.StatusCode != 200 {
-			return sender.Send(&backend.CallResourceResponse{
-				Status: resp.StatusCode,
-				Body:   body,
-			})
+		url := fmt.Sprintf("%s/v1/endpoint/%s/values/%s/%s", d.currentBaseURL(), endpointId, applianceId, serviceUri)
+		body, errResp := d.getResourceBody(ctx, url)
+		if errResp != nil {
+			return sender.Send(errResp)
 		}
 		var raw struct {
 			DataPoints map[string]struct {
@@ -698,8 +1870,8 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 				validValues = dp.ValidValues
 			}
 			// If type is BinarySetPoint or BinaryReading, set validValues to ["False", "True"]
-			 if dp.Type == "BinarySetPoint" || dp.Type == "BinaryReading" {
-			 	validValues = []string{"False", "True"}
+			if dp.Type == "BinarySetPoint" || dp.Type == "BinaryReading" {
+				validValues = []string{"False", "True"}
 			}
 		}
 		respMap := map[string]interface{}{"unit": unit}
@@ -713,6 +1885,40 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 		})
 	}
 
+	if req.Path == "appliance-config" {
+		return d.applianceConfiguration(ctx, req, sender)
+	}
+
+	if req.Path == "endpoint-status" {
+		return d.endpointStatusList(ctx, sender)
+	}
+
+	if req.Path == "export-csv" {
+		return d.exportCSV(ctx, req, sender)
+	}
+
+	if req.Path == "export-arrow" {
+		return d.exportArrow(ctx, req, sender)
+	}
+
+	if req.Path == "resolve-appliances" {
+		var params url.Values
+		if parsedUrl, err := url.Parse(req.URL); err == nil {
+			params = parsedUrl.Query()
+		}
+		return d.resolveAppliances(ctx, params, sender)
+	}
+	if req.Path == "search" {
+		return d.search(ctx, req, sender)
+	}
+
+	if req.Path == "diagnose" {
+		return d.diagnoseResource(ctx, req, sender)
+	}
+	if req.Path == "datapoint-range" {
+		return d.datapointRangeResource(ctx, req, sender)
+	}
+
 	// Unknown resource
 	return sender.Send(&backend.CallResourceResponse{
 		Status: http.StatusNotFound,
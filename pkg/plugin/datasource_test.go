@@ -2,9 +2,21 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
 func TestQueryData(t *testing.T) {
@@ -26,3 +38,920 @@ func TestQueryData(t *testing.T) {
 		t.Fatal("QueryData must return a response")
 	}
 }
+
+// newTestDatasource returns a Datasource pointing at baseURL with a token that
+// is still valid, so no token request is made.
+func newTestDatasource(baseURL string) *Datasource {
+	return &Datasource{
+		baseURL:     baseURL,
+		token:       "test-token",
+		tokenExpiry: time.Now().Add(time.Hour),
+	}
+}
+
+func TestQueryWhitespaceOnlyFields(t *testing.T) {
+	ds := newTestDatasource("http://127.0.0.1:0")
+
+	res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"endpoint_id":"  ","appliance_id":"app","service_uri":"\t","data_point":"dp"}`),
+	})
+	if res.Status != backend.StatusBadRequest {
+		t.Fatalf("expected status %v, got %v", backend.StatusBadRequest, res.Status)
+	}
+	if res.Error == nil || res.Error.Error() != ErrMissingQueryFields.Error() {
+		t.Fatalf("expected %q, got %v", ErrMissingQueryFields, res.Error)
+	}
+}
+
+func TestValidateQueryModelTrimsFields(t *testing.T) {
+	qm := WEMSQueryModel{EndpointID: " ep ", ApplianceID: "app\n", ServiceURI: " svc", DataPoint: "dp "}
+	if err := validateQueryModel(&qm); err != nil {
+		t.Fatal(err)
+	}
+	if qm.EndpointID != "ep" || qm.ApplianceID != "app" || qm.ServiceURI != "svc" || qm.DataPoint != "dp" {
+		t.Fatalf("fields not trimmed: %+v", qm)
+	}
+	if err := validateQueryModel(&WEMSQueryModel{EndpointID: " ", ApplianceID: "a", ServiceURI: "s", DataPoint: "d"}); !errors.Is(err, ErrMissingQueryFields) {
+		t.Fatalf("expected ErrMissingQueryFields, got %v", err)
+	}
+}
+
+// callResource invokes ds.CallResource and returns the single response sent.
+// Requests without a method are sent as GET.
+func callResource(t *testing.T, ds *Datasource, req *backend.CallResourceRequest) *backend.CallResourceResponse {
+	t.Helper()
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	var got *backend.CallResourceResponse
+	err := ds.CallResource(context.Background(), req, backend.CallResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		got = res
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("no resource response sent")
+	}
+	return got
+}
+
+// seriesServer returns a test server answering every request with body.
+func seriesServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// runQuery runs a single query with the given model JSON against ds.
+func runQuery(ds *Datasource, model string) backend.DataResponse {
+	return ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		JSON:      []byte(model),
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)},
+	})
+}
+
+func TestQueryPrecision(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.23456},{"time":1700000060,"value":"2.71828"}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","precision":2}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	if got := field.At(0).(float64); got != 1.23 {
+		t.Errorf("expected 1.23, got %v", got)
+	}
+	if got := field.At(1).(float64); got != 2.72 {
+		t.Errorf("expected 2.72, got %v", got)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if got := res.Frames[0].Fields[1].At(0).(float64); got != 1.23456 {
+		t.Errorf("expected unrounded 1.23456, got %v", got)
+	}
+}
+
+func TestQueryDedupeTimestamps(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700000060,"value":2},{"time":1700000060,"value":3},{"time":1700000120,"value":4}]`)
+	ds := newTestDatasource(srv.URL)
+
+	for _, tc := range []struct {
+		model string
+		want  []float64
+	}{
+		{`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`, []float64{1, 2, 3, 4}},
+		{`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","dedupe_timestamps":true}`, []float64{1, 3, 4}},
+		{`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","dedupe_timestamps":true,"dedupe_keep":"first"}`, []float64{1, 2, 4}},
+	} {
+		res := runQuery(ds, tc.model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		field := res.Frames[0].Fields[1]
+		if field.Len() != len(tc.want) {
+			t.Fatalf("%s: expected %d points, got %d", tc.model, len(tc.want), field.Len())
+		}
+		for i, want := range tc.want {
+			if got := field.At(i).(float64); got != want {
+				t.Errorf("%s: point %d: expected %v, got %v", tc.model, i, want, got)
+			}
+		}
+	}
+}
+
+func TestNewDatasourceClientSecretKey(t *testing.T) {
+	var gotSecret string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body TokenRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotSecret = body.ClientSecret
+		_, _ = w.Write([]byte("token"))
+	}))
+	defer srv.Close()
+
+	_, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id","base_url":"` + srv.URL + `","client_secret_key":"wems_secret"}`),
+		DecryptedSecureJSONData: map[string]string{"client_secret": "default", "wems_secret": "custom"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotSecret != "custom" {
+		t.Errorf("expected secret from custom key, got %q", gotSecret)
+	}
+}
+
+func TestQueryTreatAsEmpty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no data", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	ds := newTestDatasource(srv.URL)
+	if res := runQuery(ds, model); res.Error == nil {
+		t.Fatal("expected 404 to fail without treat_as_empty")
+	}
+
+	ds.settings.TreatAsEmpty = []int{404}
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Rows() != 0 {
+		t.Fatalf("expected one empty frame, got %v", res.Frames)
+	}
+}
+
+func TestConvertPointsNumberFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  convertOptions
+		value string
+		want  float64
+	}{
+		{"default", convertOptions{}, "1234.56", 1234.56},
+		{"comma decimal", convertOptions{decimalSeparator: ",", groupSeparator: "."}, "1.234,56", 1234.56},
+		{"comma grouping", convertOptions{decimalSeparator: ".", groupSeparator: ","}, "1,234.56", 1234.56},
+		{"default rejects grouping", convertOptions{}, "1,234.56", 0},
+	} {
+		_, values, _ := convertPoints([]TimeSeriesDataPoint{{Time: 1, Value: tc.value}}, tc.opts)
+		if values[0] != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, values[0])
+		}
+	}
+}
+
+func TestQueryInterpolatesScopedVars(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"${endpoint}","appliance_id":"$appliance","service_uri":"svc","data_point":"$unknown",
+		"scopedVars":{"endpoint":{"text":"Plant A","value":"ep1"},"appliance":{"text":"Meter","value":"app1"}}}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if want := "/v1/endpoint/ep1/series/app1/svc/$unknown"; gotPath != want {
+		t.Errorf("expected path %s, got %s", want, gotPath)
+	}
+}
+
+func TestQueryInterpolatesMultiApplianceVars(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"$endpoint","appliance_ids":["$appliance","app2"],"service_uri":"$service","data_point":"dp",
+		"scopedVars":{"endpoint":{"value":"ep1"},"appliance":{"value":"app1"},"service":{"value":"meter"}}}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	slices.Sort(paths)
+	want := []string{"/v1/endpoint/ep1/series/app1/meter/dp", "/v1/endpoint/ep1/series/app2/meter/dp"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("expected paths %v, got %v", want, paths)
+	}
+
+	paths = nil
+	res = runQuery(ds, `{"endpoint_id":"ep1","appliance_id":"app1",
+		"numerator":{"service_uri":"$service","data_point":"$dp"},"denominator":{"service_uri":"$service","data_point":"apparent"},
+		"scopedVars":{"service":{"value":"meter"},"dp":{"value":"active"}}}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	slices.Sort(paths)
+	want = []string{"/v1/endpoint/ep1/series/app1/meter/active", "/v1/endpoint/ep1/series/app1/meter/apparent"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("expected ratio paths %v, got %v", want, paths)
+	}
+}
+
+func TestQueryStaleNotice(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700000060,"value":2}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","stale_after_seconds":300}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	meta := res.Frames[0].Meta
+	if meta == nil || len(meta.Notices) != 1 || meta.Notices[0].Severity != data.NoticeSeverityWarning {
+		t.Fatalf("expected a stale warning notice, got %+v", meta)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Frames[0].Meta != nil && len(res.Frames[0].Meta.Notices) > 0 {
+		t.Fatalf("expected no notice when the check is disabled")
+	}
+}
+
+func TestStaleNoticeFreshSeries(t *testing.T) {
+	now := time.Unix(1700000100, 0)
+	if _, stale := staleNotice([]time.Time{time.Unix(1700000000, 0), time.Unix(1700000060, 0)}, time.Minute, now); stale {
+		t.Error("series with a point 40s old must not be stale")
+	}
+	if _, stale := staleNotice(nil, time.Minute, now); stale {
+		t.Error("empty series must not be stale")
+	}
+}
+
+func TestSeriesPathDefaultLimit(t *testing.T) {
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+	limitOf := func(ds *Datasource, maxDataPoints int64) string {
+		u, err := url.Parse(ds.seriesPath(qm, backend.DataQuery{MaxDataPoints: maxDataPoints}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Query().Get("limit")
+	}
+
+	ds := newTestDatasource("")
+	if got := limitOf(ds, 0); got != "" {
+		t.Errorf("expected no limit without default, got %q", got)
+	}
+	ds.settings.DefaultLimit = 500
+	if got := limitOf(ds, 0); got != "500" {
+		t.Errorf("expected default limit 500, got %q", got)
+	}
+	if got := limitOf(ds, 1000); got != "10000" {
+		t.Errorf("expected default limit to be ignored with MaxDataPoints, got %q", got)
+	}
+}
+
+func TestTokenAuthModes(t *testing.T) {
+	type seen struct {
+		body             TokenRequest
+		user, pass       string
+		basicAuthPresent bool
+	}
+	var got seen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = seen{}
+		_ = json.NewDecoder(r.Body).Decode(&got.body)
+		got.user, got.pass, got.basicAuthPresent = r.BasicAuth()
+		_, _ = w.Write([]byte("token"))
+	}))
+	defer srv.Close()
+	secure := map[string]string{"client_secret": "secret"}
+
+	_, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id","base_url":"` + srv.URL + `"}`),
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.basicAuthPresent || got.body.ClientID != "id" || got.body.ClientSecret != "secret" {
+		t.Errorf("json_body mode: unexpected request %+v", got)
+	}
+
+	_, err = NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id","base_url":"` + srv.URL + `","auth_mode":"basic"}`),
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.basicAuthPresent || got.user != "id" || got.pass != "secret" || got.body.ClientID != "" || got.body.ClientSecret != "" {
+		t.Errorf("basic mode: unexpected request %+v", got)
+	}
+	if !got.body.SuperToken {
+		t.Error("basic mode: expected remaining token payload to be sent")
+	}
+
+	_, err = NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"base_url":"` + srv.URL + `","auth_mode":"digest"}`),
+	})
+	if err == nil {
+		t.Error("expected unsupported auth_mode to be rejected")
+	}
+}
+
+func TestQueryDisplayDecimals(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1.23456}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","display_decimals":3}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	if field.Config == nil || field.Config.Decimals == nil || *field.Config.Decimals != 3 {
+		t.Fatalf("expected 3 display decimals, got %+v", field.Config)
+	}
+	if got := field.At(0).(float64); got != 1.23456 {
+		t.Errorf("expected display decimals not to round values, got %v", got)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","display_decimals":0,"precision":1}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field = res.Frames[0].Fields[1]
+	if field.Config == nil || field.Config.Decimals == nil || *field.Config.Decimals != 0 {
+		t.Fatalf("expected 0 display decimals, got %+v", field.Config)
+	}
+	if got := field.At(0).(float64); got != 1.2 {
+		t.Errorf("expected precision to apply independently, got %v", got)
+	}
+}
+
+func TestQueryDisplayNameAndColor(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","unit":"W","display_name":"Grid power","color":"#ff0000"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	cfg := res.Frames[0].Fields[1].Config
+	if cfg == nil || cfg.DisplayNameFromDS != "Grid power" || cfg.Unit != "W" {
+		t.Fatalf("unexpected field config %+v", cfg)
+	}
+	if cfg.Color["mode"] != "fixed" || cfg.Color["fixedColor"] != "#ff0000" {
+		t.Errorf("unexpected color %v", cfg.Color)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Frames[0].Fields[1].Config != nil {
+		t.Errorf("expected no field config, got %+v", res.Frames[0].Fields[1].Config)
+	}
+}
+
+func TestQueryConcurrentIdenticalRequestsShareUpstreamCall(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+			if res.Error != nil {
+				errs <- res.Error
+			} else if res.Frames[0].Rows() != 1 {
+				errs <- errors.New("missing points")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single upstream call, got %d", got)
+	}
+}
+
+func TestSharedSeriesRequestSurvivesCancelledCaller(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	const path = "/v1/endpoint/ep/series/app/svc/dp?from=1700000000"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := ds.fetchSeries(ctx, path)
+		first <- err
+	}()
+	type result struct {
+		points []TimeSeriesDataPoint
+		err    error
+	}
+	second := make(chan result, 1)
+	time.AfterFunc(20*time.Millisecond, func() {
+		go func() {
+			points, err := ds.fetchSeries(context.Background(), path)
+			second <- result{points, err}
+		}()
+		time.AfterFunc(20*time.Millisecond, cancel)
+	})
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the first caller to be cancelled, got %v", err)
+	}
+	close(release)
+	res := <-second
+	if res.err != nil || len(res.points) != 1 {
+		t.Fatalf("expected the second caller to get the points, got %v, %v", res.points, res.err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single upstream call, got %d", got)
+	}
+}
+
+func TestSharedSeriesRequestAcrossRetryBudgets(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxRetries = 2
+	const path = "/v1/endpoint/ep/series/app/svc/dp?from=1700000000"
+
+	// Each QueryData call has a retry budget of its own.
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := ds.fetchSeries(withRetryBudget(context.Background(), ds.retryBudget()), path)
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single upstream call, got %d", got)
+	}
+}
+
+func TestTokenFailureBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL}
+
+	for i := 0; i < 3; i++ {
+		if err := ds.getTokenIfNeeded(context.Background()); err == nil {
+			t.Fatal("expected token error")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected one token attempt within the backoff window, got %d", got)
+	}
+
+	// Once the window has passed the endpoint is tried again.
+	ds.tokenRetryAt = time.Now().Add(-time.Millisecond)
+	_ = ds.getTokenIfNeeded(context.Background())
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected a new attempt after the backoff window, got %d", got)
+	}
+}
+
+func TestTokenRefreshSingleFlight(t *testing.T) {
+	var tokenCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/token" {
+			tokenCalls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte("fresh-token"))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.tokenExpiry = time.Now().Add(-time.Minute)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`); res.Error != nil {
+				errs <- res.Error
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := tokenCalls.Load(); got != 1 {
+		t.Errorf("expected a single token request, got %d", got)
+	}
+	if ds.currentToken() != "fresh-token" {
+		t.Errorf("expected refreshed token, got %q", ds.currentToken())
+	}
+}
+
+func TestTokenRequestSurvivesCancelledCaller(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("fresh-token"))
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL, clientID: "id", clientSecret: "secret"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() { first <- ds.getTokenIfNeeded(ctx) }()
+	second := make(chan error, 1)
+	time.AfterFunc(20*time.Millisecond, func() {
+		go func() { second <- ds.getTokenIfNeeded(context.Background()) }()
+		time.AfterFunc(20*time.Millisecond, cancel)
+	})
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the first caller to be cancelled, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("expected the second caller to get a token, got %v", err)
+	}
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if ds.token != "fresh-token" || ds.tokenErr != nil {
+		t.Errorf("expected a stored token and no failure, got %q, %v", ds.token, ds.tokenErr)
+	}
+}
+
+func TestQueryBoolMapping(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":true},{"time":1700000060,"value":false}]`)
+	ds := newTestDatasource(srv.URL)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	for _, tc := range []struct {
+		mapping BoolMapping
+		want    []float64
+	}{
+		{BoolMapping{}, []float64{1, 0}},
+		{BoolMapping{TrueValue: ptr(0.0), FalseValue: ptr(1.0)}, []float64{0, 1}},
+		{BoolMapping{TrueValue: ptr(100.0)}, []float64{100, 0}},
+	} {
+		ds.settings.BoolMapping = tc.mapping
+		res := runQuery(ds, model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		field := res.Frames[0].Fields[1]
+		for i, want := range tc.want {
+			if got := field.At(i).(float64); got != want {
+				t.Errorf("mapping %+v: point %d: expected %v, got %v", tc.mapping, i, want, got)
+			}
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestQuerySoftErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`),
+	}}}
+
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := resp.Responses["A"]; res.Error == nil || len(res.Frames) != 0 {
+		t.Fatalf("expected hard error by default, got %+v", res)
+	}
+
+	ds.settings.SoftErrors = true
+	resp, err = ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("expected no error response, got %v", res.Error)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Meta == nil || len(res.Frames[0].Meta.Notices) != 1 {
+		t.Fatalf("expected one frame with a notice, got %+v", res.Frames)
+	}
+	notice := res.Frames[0].Meta.Notices[0]
+	if notice.Severity != data.NoticeSeverityError || !strings.Contains(notice.Text, "boom") {
+		t.Errorf("unexpected notice %+v", notice)
+	}
+}
+
+func TestTokenAccepts2xx(t *testing.T) {
+	for _, status := range []int{http.StatusCreated, http.StatusAccepted} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("fresh-token"))
+		}))
+		ds := &Datasource{baseURL: srv.URL}
+		if err := ds.getTokenIfNeeded(context.Background()); err != nil {
+			t.Errorf("status %d: unexpected error %v", status, err)
+		} else if ds.currentToken() != "fresh-token" {
+			t.Errorf("status %d: expected token to be parsed, got %q", status, ds.currentToken())
+		}
+		srv.Close()
+	}
+}
+
+func TestQueryTimesAreUTC(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	got := res.Frames[0].Fields[0].At(0).(time.Time)
+	if got.Location() != time.UTC || got.Unix() != 1700000000 {
+		t.Errorf("expected 1700000000 in UTC, got %v", got)
+	}
+}
+
+func TestSeriesPathTimezone(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+	query := backend.DataQuery{TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)}}
+
+	if path := ds.seriesPath(qm, query); strings.Contains(path, "timezone") {
+		t.Errorf("expected no timezone parameter, got %s", path)
+	}
+	ds.settings.Timezone = "America/New_York"
+	if path := ds.seriesPath(qm, query); !strings.Contains(path, "&timezone=America%2FNew_York") {
+		t.Errorf("expected timezone parameter, got %s", path)
+	}
+
+	if _, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{JSONData: []byte(`{"timezone":"Mars/Olympus"}`)}); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+}
+
+func TestSeriesPathMinInterval(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	ds.minInterval = time.Minute
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+
+	for _, tc := range []struct {
+		interval time.Duration
+		want     string
+	}{
+		{10 * time.Second, "aggregateInterval=60s"},
+		{5 * time.Minute, "aggregateInterval=300s"},
+		{3 * 365 * 24 * time.Hour, "aggregateInterval=2592000s"},
+	} {
+		query := backend.DataQuery{Interval: tc.interval, TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)}}
+		if path := ds.seriesPath(qm, query); !strings.Contains(path, tc.want) {
+			t.Errorf("interval %s: expected %s, got %s", tc.interval, tc.want, path)
+		}
+	}
+
+	if _, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{JSONData: []byte(`{"min_interval":"often"}`)}); err == nil {
+		t.Error("expected error for invalid min_interval")
+	}
+}
+
+func TestSeriesPathAggregateNone(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	ds.settings.DefaultAggregateFunction = "max"
+	query := backend.DataQuery{Interval: time.Minute, TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)}}
+
+	for _, tc := range []struct {
+		aggregate string
+		want      []string
+		absent    []string
+	}{
+		{"", []string{"aggregateFunction=max", "aggregateInterval=60s"}, nil},
+		{"mean", []string{"aggregateFunction=mean", "aggregateInterval=60s"}, nil},
+		{"none", nil, []string{"aggregateFunction", "aggregateInterval"}},
+	} {
+		qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp", AggregateFunction: tc.aggregate}
+		path := ds.seriesPath(qm, query)
+		for _, want := range tc.want {
+			if !strings.Contains(path, want) {
+				t.Errorf("aggregate %q: expected %s, got %s", tc.aggregate, want, path)
+			}
+		}
+		for _, absent := range tc.absent {
+			if strings.Contains(path, absent) {
+				t.Errorf("aggregate %q: expected no %s, got %s", tc.aggregate, absent, path)
+			}
+		}
+	}
+}
+
+func TestSeriesPathQueryDelay(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	ds.settings.QueryDelaySeconds = 30
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+
+	now := time.Now()
+	query := backend.DataQuery{TimeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now}}
+	want := now.Add(-30 * time.Second).Unix()
+	path := ds.seriesPath(qm, query)
+	if !strings.Contains(path, fmt.Sprintf("to=%d", want)) && !strings.Contains(path, fmt.Sprintf("to=%d", want-1)) {
+		t.Errorf("expected to shifted back by 30s, got %s", path)
+	}
+
+	// Ranges ending well before now are not affected.
+	query.TimeRange = backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)}
+	if path := ds.seriesPath(qm, query); !strings.Contains(path, "to=1700003600") {
+		t.Errorf("expected unchanged to, got %s", path)
+	}
+
+	if _, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{JSONData: []byte(`{"query_delay_seconds":-5}`)}); err == nil {
+		t.Error("expected error for negative query_delay_seconds")
+	}
+}
+
+func TestCheckHealthTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	ds := &Datasource{baseURL: srv.URL, clientID: "id", clientSecret: "secret"}
+	ds.settings.ClientID, ds.settings.ClientSecret = "id", "secret"
+	ds.settings.BaseURL = srv.URL
+	ds.settings.HealthCheckTimeoutSeconds = 1
+
+	start := time.Now()
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusError {
+		t.Errorf("expected health check to fail, got %+v", res)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected health check to give up after 1s, took %s", elapsed)
+	}
+}
+
+func TestTokenQuotedBody(t *testing.T) {
+	for body, want := range map[string]string{
+		`"eyJ.quoted"`:  "eyJ.quoted",
+		"\"eyJ.nl\"\n":  "eyJ.nl",
+		"plain-token\n": "plain-token",
+		`"unterminated`: `"unterminated`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		ds := &Datasource{baseURL: srv.URL}
+		if err := ds.getTokenIfNeeded(context.Background()); err != nil {
+			t.Errorf("%q: unexpected error %v", body, err)
+		} else if ds.currentToken() != want {
+			t.Errorf("%q: expected token %q, got %q", body, want, ds.currentToken())
+		}
+		srv.Close()
+	}
+}
+
+func TestQueryEmptyJSON(t *testing.T) {
+	ds := newTestDatasource("http://127.0.0.1:0")
+
+	for _, body := range []string{"", "  ", "null"} {
+		res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{RefID: "A", JSON: []byte(body)})
+		if res.Error != nil {
+			t.Errorf("%q: expected a notice instead of an error, got %v", body, res.Error)
+			continue
+		}
+		if len(res.Frames) != 1 || res.Frames[0].Meta == nil || len(res.Frames[0].Meta.Notices) != 1 ||
+			!strings.HasPrefix(res.Frames[0].Meta.Notices[0].Text, "Empty query") {
+			t.Errorf("%q: expected an empty query notice, got %+v", body, res.Frames)
+		}
+	}
+}
+
+func TestQueryDuplicateRefIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	query := func(refID, datapoint string) backend.DataQuery {
+		return backend.DataQuery{
+			RefID: refID,
+			JSON:  []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"` + datapoint + `"}`),
+		}
+	}
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{query("A", "first"), query("B", "other"), query("A", "last")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := resp.Responses["A"]
+	if a.Error != nil || len(a.Frames) != 1 || a.Frames[0].Name != "ep/app/svc/last" {
+		t.Fatalf("expected the last query to win, got %+v", a)
+	}
+	if a.Frames[0].Meta == nil || len(a.Frames[0].Meta.Notices) != 1 || !strings.Contains(a.Frames[0].Meta.Notices[0].Text, "used by 2 queries") {
+		t.Errorf("expected a duplicate RefID warning, got %+v", a.Frames[0].Meta)
+	}
+	if b := resp.Responses["B"]; b.Frames[0].Meta != nil && len(b.Frames[0].Meta.Notices) > 0 {
+		t.Errorf("expected no warning for a unique RefID, got %+v", b.Frames[0].Meta.Notices)
+	}
+}
+
+func TestQueryDropsZeroTimePoints(t *testing.T) {
+	srv := seriesServer(t, `[{"time":0,"value":5},{"time":1700000000,"value":1},{"value":7},{"time":1700000060,"value":2}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 2 {
+		t.Fatalf("expected 2 points, got %d", frame.Rows())
+	}
+	for i := 0; i < frame.Rows(); i++ {
+		if ts := frame.Fields[0].At(i).(time.Time); ts.Unix() < 1700000000 {
+			t.Errorf("point %d plotted at %s", i, ts)
+		}
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "Dropped 2 points") {
+		t.Errorf("expected a notice counting the dropped points, got %+v", frame.Meta)
+	}
+}
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// DefaultMaxResponseBytes is the default cap on the size of a series response.
+const DefaultMaxResponseBytes = 64 << 20
+
+// ErrResponseTooLarge is returned when a response exceeds the size cap.
+var ErrResponseTooLarge = errors.New("WEMS response exceeds the maximum size")
+
+// ErrResponseTruncated is returned when a series response ends in the middle
+// of the JSON document.
+var ErrResponseTruncated = errors.New("response truncated, upstream may have closed connection")
+
+// truncationError wraps err in ErrResponseTruncated if it reports an early end
+// of the body, that is io.ErrUnexpectedEOF after part of a document was read.
+// An empty body yields io.EOF instead and is not a truncation: it is returned
+// as an invalid body, which RetryTruncated does not repeat.
+func truncationError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("empty response body: %w", err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrResponseTruncated, err)
+	}
+	return err
+}
+
+func (d *Datasource) maxResponseBytes() int64 {
+	if d.settings.MaxResponseBytes > 0 {
+		return d.settings.MaxResponseBytes
+	}
+	return DefaultMaxResponseBytes
+}
+
+// capBody limits body to max bytes. Unlike io.LimitReader, reading past the
+// cap fails with ErrResponseTooLarge instead of silently ending the stream,
+// which matters for chunked responses that carry no Content-Length.
+func capBody(body io.ReadCloser, max int64) io.ReadCloser {
+	return &cappedBody{ReadCloser: body, remaining: max}
+}
+
+type cappedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (c *cappedBody) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		// The cap is reached; any further byte means the body is too large.
+		var probe [1]byte
+		n, err := c.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.ReadCloser.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// seriesAccept returns the Accept header to send with series requests.
+func (d *Datasource) seriesAccept() string {
+	if d.settings.SeriesAccept != "" {
+		return d.settings.SeriesAccept
+	}
+	return contentTypeJSON
+}
+
+// decodePoints decodes a series response like decodeSeriesPage, ignoring any
+// continuation cursor.
+func decodePoints(resp *http.Response) ([]TimeSeriesDataPoint, error) {
+	points, _, err := decodeSeriesPage(resp)
+	return points, err
+}
+
+// seriesPage is a JSON series response in the paginated form, holding the
+// points of one page and the cursor of the next one, if any.
+type seriesPage struct {
+	Points     []TimeSeriesDataPoint `json:"points"`
+	NextCursor string                `json:"nextCursor"`
+}
+
+// decodeSeriesPage decodes a series response according to its Content-Type
+// and returns its points together with the cursor of the next page. A
+// newline-delimited JSON body holds one point per line; anything else is
+// decoded as a JSON array of points or as a seriesPage object. Numeric values
+// are decoded with numberValue. Bodies cut off mid-document fail with
+// ErrResponseTruncated.
+func decodeSeriesPage(resp *http.Response) ([]TimeSeriesDataPoint, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	var points []TimeSeriesDataPoint
+	if mediaType != contentTypeNDJSON {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, "", truncationError(err)
+		}
+		var page seriesPage
+		var target interface{} = &page.Points
+		if len(raw) > 0 && raw[0] == '{' {
+			target = &page
+		}
+		pageDec := json.NewDecoder(bytes.NewReader(raw))
+		pageDec.UseNumber()
+		if err := pageDec.Decode(target); err != nil {
+			return nil, "", err
+		}
+		for i := range page.Points {
+			page.Points[i].Value = numberValue(page.Points[i].Value)
+		}
+		return page.Points, page.NextCursor, nil
+	}
+	for {
+		var p TimeSeriesDataPoint
+		err := dec.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return points, "", nil
+		}
+		if err != nil {
+			return nil, "", truncationError(err)
+		}
+		p.Value = numberValue(p.Value)
+		points = append(points, p)
+	}
+}
+
+// numberValue converts a number decoded as json.Number to an int64 if it is
+// an integer in range, so counters above 2^53 keep their exact value, and to
+// a float64 otherwise. Other values are returned unchanged.
+func numberValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
@@ -0,0 +1,204 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSeriesAcceptHeader(t *testing.T) {
+	var gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept")
+		if gotAccept == contentTypeNDJSON {
+			w.Header().Set("Content-Type", contentTypeNDJSON+"; charset=utf-8")
+			_, _ = w.Write([]byte("{\"time\":1700000000,\"value\":1}\n{\"time\":1700000060,\"value\":2}\n"))
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeJSON)
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	ds := newTestDatasource(srv.URL)
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if gotAccept != contentTypeJSON {
+		t.Errorf("expected default Accept %s, got %s", contentTypeJSON, gotAccept)
+	}
+
+	ds.settings.SeriesAccept = contentTypeNDJSON
+	res = runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if gotAccept != contentTypeNDJSON {
+		t.Errorf("expected configured Accept %s, got %s", contentTypeNDJSON, gotAccept)
+	}
+	if res.Frames[0].Rows() != 2 {
+		t.Errorf("expected 2 points from NDJSON, got %d", res.Frames[0].Rows())
+	}
+}
+
+// chunkedSeriesServer streams n points as a chunked response without a
+// Content-Length.
+func chunkedSeriesServer(t *testing.T, n int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		_, _ = w.Write([]byte("["))
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				_, _ = w.Write([]byte(","))
+			}
+			_, _ = fmt.Fprintf(w, `{"time":%d,"value":%d}`, 1700000000+i, i)
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte("]"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestChunkedSeriesResponse(t *testing.T) {
+	srv := chunkedSeriesServer(t, 500)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	ds := newTestDatasource(srv.URL)
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Rows(); got != 500 {
+		t.Fatalf("expected all 500 chunked points, got %d", got)
+	}
+
+	ds.settings.MaxResponseBytes = 1024
+	res = runQuery(ds, model)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), ErrResponseTooLarge.Error()) {
+		t.Fatalf("expected size cap error, got %v", res.Error)
+	}
+}
+
+func TestDecodePointsPreservesLargeIntegers(t *testing.T) {
+	const counter = int64(1)<<53 + 1
+	for _, tc := range []struct{ contentType, body string }{
+		{contentTypeJSON, fmt.Sprintf(`[{"time":1700000000,"value":%d},{"time":1700000060,"value":1.5},{"time":1700000120,"value":"7"}]`, counter)},
+		{contentTypeNDJSON, fmt.Sprintf("{\"time\":1700000000,\"value\":%d}\n{\"time\":1700000060,\"value\":1.5}\n{\"time\":1700000120,\"value\":\"7\"}\n", counter)},
+	} {
+		resp := &http.Response{
+			Header: http.Header{"Content-Type": {tc.contentType}},
+			Body:   io.NopCloser(strings.NewReader(tc.body)),
+		}
+		points, err := decodePoints(resp)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.contentType, err)
+		}
+		if len(points) != 3 {
+			t.Fatalf("%s: expected 3 points, got %d", tc.contentType, len(points))
+		}
+		if v, ok := points[0].Value.(int64); !ok || v != counter {
+			t.Errorf("%s: expected exact counter %d, got %#v", tc.contentType, counter, points[0].Value)
+		}
+		if v, ok := points[1].Value.(float64); !ok || v != 1.5 {
+			t.Errorf("%s: expected float 1.5, got %#v", tc.contentType, points[1].Value)
+		}
+		if v, ok := points[2].Value.(string); !ok || v != "7" {
+			t.Errorf("%s: expected string to be kept, got %#v", tc.contentType, points[2].Value)
+		}
+	}
+
+	srv := seriesServer(t, fmt.Sprintf(`[{"time":1700000000,"value":%d}]`, counter))
+	ds := newTestDatasource(srv.URL)
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","mode":"raw"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Fields[1].At(0); got != fmt.Sprint(counter) {
+		t.Errorf("expected raw value %d, got %v", counter, got)
+	}
+}
+
+func TestTruncatedSeriesResponse(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1},{"time":17000`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1},{"time":1700000060,"value":2}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	res := runQuery(ds, model)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), ErrResponseTruncated.Error()) {
+		t.Fatalf("expected truncation error, got %v", res.Error)
+	}
+
+	calls.Store(0)
+	ds.settings.RetryTruncated = true
+	res = runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected one retry, got %d requests", got)
+	}
+	if rows := res.Frames[0].Rows(); rows != 2 {
+		t.Errorf("expected 2 points, got %d", rows)
+	}
+}
+
+func TestEmptySeriesResponseIsNotTruncated(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.RetryTruncated = true
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error == nil || strings.Contains(res.Error.Error(), ErrResponseTruncated.Error()) || !strings.Contains(res.Error.Error(), "empty response body") {
+		t.Fatalf("expected an empty body error, got %v", res.Error)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected no retry, got %d requests", got)
+	}
+}
+
+func TestQueryKeepsLargeIntegerSeriesExact(t *testing.T) {
+	const counter = int64(1)<<53 + 1
+	srv := seriesServer(t, fmt.Sprintf(`[{"time":1700000000,"value":%d},{"time":1700000060,"value":%d}]`, counter, counter+2))
+	ds := newTestDatasource(srv.URL)
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field := res.Frames[0].Fields[1]
+	for i, want := range []int64{counter, counter + 2} {
+		if got, ok := field.At(i).(int64); !ok || got != want {
+			t.Errorf("value %d: expected exact int64 %d, got %#v", i, want, field.At(i))
+		}
+	}
+
+	// Integers a float64 represents exactly keep the usual float64 field.
+	srv = seriesServer(t, `[{"time":1700000000,"value":42}]`)
+	ds = newTestDatasource(srv.URL)
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got, ok := res.Frames[0].Fields[1].At(0).(float64); !ok || got != 42 {
+		t.Errorf("expected float64 42, got %#v", res.Frames[0].Fields[1].At(0))
+	}
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// diagnoseMaxRange bounds the time range of a diagnose request, which is
+// meant for a small sample rather than a full series.
+const diagnoseMaxRange = 24 * time.Hour
+
+// diagnoseSampleSize is the number of decoded points returned by diagnose.
+const diagnoseSampleSize = 5
+
+// diagnoseRequest is the body of the diagnose resource: a query model as sent
+// by the query editor and a range given as Unix seconds. An empty range
+// covers the last hour.
+type diagnoseRequest struct {
+	Query WEMSQueryModel `json:"query"`
+	From  int64          `json:"from"`
+	To    int64          `json:"to"`
+}
+
+// diagnoseResult describes a single series request made by the diagnose
+// resource. Error is set if WEMS could not be reached, answered with an
+// error or sent a body that could not be decoded.
+type diagnoseResult struct {
+	URL        string                `json:"url"`
+	Status     int                   `json:"status,omitempty"`
+	RequestID  string                `json:"requestId,omitempty"`
+	DurationMs int64                 `json:"durationMs"`
+	PointCount int                   `json:"pointCount"`
+	Sample     []TimeSeriesDataPoint `json:"sample"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// diagnoseResource serves the diagnose resource. It requests the series of
+// the posted query like a panel query would, bypassing the request sharing
+// and caches, and reports what happened.
+func (d *Datasource) diagnoseResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	var dr diagnoseRequest
+	if err := json.Unmarshal(req.Body, &dr); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Invalid diagnose request: " + err.Error()),
+		})
+	}
+	qm := dr.Query
+	interpolateQueryModel(&qm)
+	err := validateQueryModel(&qm)
+	if err == nil && (qm.ServiceURI == "" || qm.DataPoint == "") {
+		err = ErrMissingQueryFields
+	}
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(err.Error()),
+		})
+	}
+
+	to, from := time.Now(), time.Now().Add(-time.Hour)
+	if dr.From != 0 || dr.To != 0 {
+		from, to = time.Unix(dr.From, 0), time.Unix(dr.To, 0)
+	}
+	if !from.Before(to) || to.Sub(from) > diagnoseMaxRange {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(fmt.Sprintf("invalid range: must be positive and at most %s", diagnoseMaxRange)),
+		})
+	}
+
+	if d.settings.PerEndpointTokens {
+		token, err := d.endpointToken(ctx, qm.EndpointID)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   []byte("Token error: " + err.Error()),
+			})
+		}
+		ctx = withBearer(ctx, token)
+	}
+	path := d.seriesPath(qm, backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: to}})
+	return sendJSON(sender, d.diagnoseSeries(ctx, path))
+}
+
+// diagnoseSeries requests the series at path through requestSeries, with its
+// pagination, failover and retries, and records the outcome of the last
+// request made. With ErrorVerbosityMinimal, WEMS error bodies are left out.
+func (d *Datasource) diagnoseSeries(ctx context.Context, path string) (result diagnoseResult) {
+	result = diagnoseResult{URL: d.currentBaseURL() + path, Sample: []TimeSeriesDataPoint{}}
+	trace := &seriesTrace{}
+	start := time.Now()
+	points, err := d.requestSeries(withSeriesTrace(ctx, trace), path)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if trace.url != "" {
+		result.URL = trace.url
+	}
+	result.Status, result.RequestID = trace.status, trace.requestID
+	if err != nil {
+		result.Error = d.redact(d.errorText(err))
+		return result
+	}
+	result.PointCount = len(points)
+	result.Sample = append(result.Sample, points[:min(len(points), diagnoseSampleSize)]...)
+	return result
+}
+
+// seriesTrace records the last series request made with a context, see
+// withSeriesTrace.
+type seriesTrace struct {
+	url       string
+	status    int
+	requestID string
+}
+
+type seriesTraceKey struct{}
+
+// withSeriesTrace returns a context whose series requests are recorded in t.
+func withSeriesTrace(ctx context.Context, t *seriesTrace) context.Context {
+	return context.WithValue(ctx, seriesTraceKey{}, t)
+}
+
+// seriesTraceOf returns the series trace of ctx, or nil if it has none.
+func seriesTraceOf(ctx context.Context) *seriesTrace {
+	t, _ := ctx.Value(seriesTraceKey{}).(*seriesTrace)
+	return t
+}
+
+// request records a request to url. A nil trace records nothing.
+func (t *seriesTrace) request(url string) {
+	if t != nil {
+		*t = seriesTrace{url: url}
+	}
+}
+
+// response records the status and request ID of resp. A nil trace records
+// nothing.
+func (t *seriesTrace) response(resp *http.Response) {
+	if t != nil {
+		t.status, t.requestID = resp.StatusCode, requestIDOf(resp)
+	}
+}
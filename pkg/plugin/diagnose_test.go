@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDiagnoseResource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/endpoint/ep/series/app/svc/dp" {
+			http.Error(w, "no such datapoint", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1},{"time":1700000060,"value":"2.5"},{"time":1700000120,"value":3},` +
+			`{"time":1700000180,"value":4},{"time":1700000240,"value":5},{"time":1700000300,"value":6}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path:   "diagnose",
+		Method: http.MethodPost,
+		Body:   []byte(`{"query":{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"},"from":1700000000,"to":1700003600}`),
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var result diagnoseResult
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.URL, srv.URL+"/v1/endpoint/ep/series/app/svc/dp?") || !strings.Contains(result.URL, "from=1700000000") {
+		t.Errorf("unexpected url %q", result.URL)
+	}
+	if result.Status != http.StatusOK || result.RequestID == "" || result.Error != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.DurationMs < 0 || result.PointCount != 6 || len(result.Sample) != diagnoseSampleSize {
+		t.Errorf("unexpected counts %+v", result)
+	}
+	if result.Sample[1].Time != 1700000060 || result.Sample[1].Value != "2.5" {
+		t.Errorf("unexpected sample %+v", result.Sample)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{
+		Path:   "diagnose",
+		Method: http.MethodPost,
+		Body:   []byte(`{"query":{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"other"},"from":1700000000,"to":1700003600}`),
+	})
+	result = diagnoseResult{}
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != http.StatusNotFound || result.RequestID == "" || !strings.Contains(result.Error, "no such datapoint") {
+		t.Errorf("unexpected error result %+v", result)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{
+		Path:   "diagnose",
+		Method: http.MethodPost,
+		Body:   []byte(`{"query":{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"},"from":1700000000,"to":1800000000}`),
+	})
+	if res.Status != http.StatusBadRequest {
+		t.Errorf("expected bad request for a wide range, got %d", res.Status)
+	}
+}
+
+func TestDiagnoseFollowsSeriesCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"points":[{"time":1700000000,"value":1}],"nextCursor":"page2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"points":[{"time":1700000060,"value":2}]}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path:   "diagnose",
+		Method: http.MethodPost,
+		Body:   []byte(`{"query":{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"},"from":1700000000,"to":1700003600}`),
+	})
+	var result diagnoseResult
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.PointCount != 2 || result.Status != http.StatusOK {
+		t.Errorf("expected 2 points from 2 pages, got %+v", result)
+	}
+	if !strings.Contains(result.URL, "cursor=page2") {
+		t.Errorf("expected the URL of the last page, got %q", result.URL)
+	}
+}
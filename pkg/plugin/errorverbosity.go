@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Error verbosity levels, see DatasourceSettings.ErrorVerbosity.
+const (
+	// ErrorVerbosityFull shows the complete error, including WEMS response
+	// bodies, in the panel.
+	ErrorVerbosityFull = "full"
+	// ErrorVerbosityMinimal shows a generic message in the panel and only
+	// logs the complete error.
+	ErrorVerbosityMinimal = "minimal"
+)
+
+// minimalErrorResponse logs the error of a failed query response and replaces
+// it with a generic message that does not reveal upstream details. Bad
+// requests keep their message, as it describes the query rather than WEMS.
+func (d *Datasource) minimalErrorResponse(refID string, res backend.DataResponse) backend.DataResponse {
+	if res.Status == backend.StatusBadRequest {
+		return res
+	}
+	log.DefaultLogger.Warn("Query failed", "refId", refID, "status", res.Status, "error", d.redact(res.Error.Error()))
+	res.Error = fmt.Errorf("query failed with status %d, see the Grafana server log for details", res.Status)
+	return res
+}
+
+// errorText returns the text of err to show in the panel outside of the query
+// error, such as in frame notices. Under minimal verbosity it is replaced by a
+// generic message and the complete error is logged instead.
+func (d *Datasource) errorText(err error) string {
+	if d.settings.ErrorVerbosity != ErrorVerbosityMinimal {
+		return err.Error()
+	}
+	log.DefaultLogger.Warn("WEMS request failed", "error", d.redact(err.Error()))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("status %d, see the Grafana server log for details", apiErr.StatusCode)
+	}
+	return "see the Grafana server log for details"
+}
@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestErrorVerbosityMinimal(t *testing.T) {
+	logger := &recordingLogger{}
+	orig := log.DefaultLogger
+	log.DefaultLogger = logger
+	defer func() { log.DefaultLogger = orig }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal db host db-7.wems.local unreachable", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{RefID: "A", JSON: []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)},
+		{RefID: "B", JSON: []byte(`{"endpoint_id":"ep"}`)},
+	}}
+
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := resp.Responses["A"]; res.Error == nil || !strings.Contains(res.Error.Error(), "db-7.wems.local") {
+		t.Fatalf("expected the full error by default, got %v", res.Error)
+	}
+
+	ds.settings.ErrorVerbosity = ErrorVerbosityMinimal
+	resp, err = ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error == nil || strings.Contains(res.Error.Error(), "db-7.wems.local") {
+		t.Fatalf("expected a generic error, got %v", res.Error)
+	}
+	if res.Status != backend.StatusInternal {
+		t.Errorf("expected status %v, got %v", backend.StatusInternal, res.Status)
+	}
+	if res := resp.Responses["B"]; res.Error == nil || res.Error.Error() != ErrMissingQueryFields.Error() {
+		t.Errorf("expected bad request message to be kept, got %v", res.Error)
+	}
+
+	logged := strings.Join(logger.lines, "\n")
+	if !strings.Contains(logged, "db-7.wems.local") {
+		t.Errorf("expected the full error to be logged, got:\n%s", logged)
+	}
+}
+
+func TestErrorVerbosityMinimalNotices(t *testing.T) {
+	const secret = "db-7.wems.local unreachable"
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/app2/"), r.URL.Path == "/v1/endpoint/ep2/description", fail.Load():
+			http.Error(w, secret, http.StatusBadGateway)
+		case r.URL.Path == "/v1/endpoint/ep1/description":
+			_, _ = w.Write([]byte(`{"friendlyName":"Plant A","online":true,"processes":[]}`))
+		default:
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":42}]`))
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ErrorVerbosity = ErrorVerbosityMinimal
+	ds.settings.ServeStaleOnError = true
+
+	single := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{RefID: "A", JSON: []byte(single)},
+		{RefID: "B", JSON: []byte(`{"endpoint_id":"ep","appliance_ids":["app1","app2"],"service_uri":"svc","data_point":"dp"}`)},
+		{RefID: "C", QueryType: QueryTypeFleetStatus, JSON: []byte(`{"endpoint_ids":["ep1","ep2"]}`)},
+	}}
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(true)
+	req.Queries = req.Queries[:1]
+	stale, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(false)
+	req.Queries = []backend.DataQuery{
+		{RefID: "B", JSON: []byte(`{"endpoint_id":"ep","appliance_ids":["app1","app2"],"service_uri":"svc","data_point":"dp"}`)},
+		{RefID: "C", QueryType: QueryTypeFleetStatus, JSON: []byte(`{"endpoint_ids":["ep1","ep2"]}`)},
+	}
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Responses["A"] = stale.Responses["A"]
+
+	notices := 0
+	for refID, res := range resp.Responses {
+		if res.Error != nil {
+			t.Fatalf("%s: unexpected error %v", refID, res.Error)
+		}
+		for _, frame := range res.Frames {
+			if frame.Meta != nil {
+				notices += len(frame.Meta.Notices)
+			}
+			b, err := json.Marshal(frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(b), secret) {
+				t.Errorf("%s: upstream body leaked into the response: %s", refID, b)
+			}
+		}
+	}
+	if notices != 3 {
+		t.Errorf("expected a stale, an appliance and a fleet notice, got %d notices", notices)
+	}
+}
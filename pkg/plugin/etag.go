@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"net/http"
+	"time"
+)
+
+// etagCacheTTL is how long a resource body fetched with an ETag is kept for
+// conditional requests. A 304 refreshes it.
+const etagCacheTTL = time.Hour
+
+// etagEntry is a resource body together with the ETag WEMS sent for it.
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+// setIfNoneMatch adds an If-None-Match header for the body cached for url, if
+// conditional requests are enabled and there is one.
+func (d *Datasource) setIfNoneMatch(req *http.Request, url string) {
+	if !d.settings.ConditionalRequests {
+		return
+	}
+	if entry, ok := d.etags.Get(url); ok {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+}
+
+// notModifiedBody returns the cached body for url if WEMS answered a
+// conditional request with 304 Not Modified.
+func (d *Datasource) notModifiedBody(resp *http.Response, url string) ([]byte, bool) {
+	if !d.settings.ConditionalRequests || resp.StatusCode != http.StatusNotModified {
+		return nil, false
+	}
+	entry, ok := d.etags.Get(url)
+	if !ok {
+		return nil, false
+	}
+	d.etags.Set(url, entry, etagCacheTTL)
+	return entry.body, true
+}
+
+// rememberETag caches body for conditional requests to url if WEMS sent an
+// ETag with it.
+func (d *Datasource) rememberETag(resp *http.Response, url string, body []byte) {
+	if !d.settings.ConditionalRequests {
+		return
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		d.etags.Set(url, etagEntry{etag: etag, body: body}, etagCacheTTL)
+	}
+}
@@ -0,0 +1,210 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// parseSeriesParams reads a series query from resource URL parameters. It
+// accepts the same fields as a panel query plus a from/to range given as Unix
+// seconds.
+func parseSeriesParams(rawURL string) (WEMSQueryModel, backend.DataQuery, error) {
+	var qm WEMSQueryModel
+	var query backend.DataQuery
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil {
+		return qm, query, err
+	}
+	params := parsedUrl.Query()
+	qm.EndpointID = params.Get("endpointId")
+	qm.ApplianceID = params.Get("applianceId")
+	qm.ServiceURI = params.Get("serviceUri")
+	qm.DataPoint = params.Get("datapoint")
+	qm.AggregateFunction = params.Get("aggregateFunction")
+	if v := params.Get("createEmptyValues"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return qm, query, errInvalidParam("createEmptyValues")
+		}
+		qm.CreateEmptyValues = &b
+	}
+	if err := validateQueryModel(&qm); err != nil {
+		return qm, query, err
+	}
+	from, err := strconv.ParseInt(params.Get("from"), 10, 64)
+	if err != nil {
+		return qm, query, errInvalidParam("from")
+	}
+	to, err := strconv.ParseInt(params.Get("to"), 10, 64)
+	if err != nil {
+		return qm, query, errInvalidParam("to")
+	}
+	query.TimeRange = backend.TimeRange{From: time.Unix(from, 0), To: time.Unix(to, 0)}
+	return qm, query, nil
+}
+
+// Timestamp units for exported rows, see formatExportTime.
+const (
+	// ExportTimeUnitSeconds writes Unix seconds.
+	ExportTimeUnitSeconds = "s"
+	// ExportTimeUnitMillis writes Unix milliseconds.
+	ExportTimeUnitMillis = "ms"
+)
+
+// parseExportTimeUnit reads the timeUnit parameter of an export resource. An
+// empty unit keeps RFC 3339 timestamps.
+func parseExportTimeUnit(rawURL string) (string, error) {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	switch unit := parsedUrl.Query().Get("timeUnit"); unit {
+	case "", ExportTimeUnitSeconds, ExportTimeUnitMillis:
+		return unit, nil
+	default:
+		return "", errInvalidParam("timeUnit")
+	}
+}
+
+// formatExportTime serializes t in the given export time unit.
+func formatExportTime(t time.Time, unit string) string {
+	switch unit {
+	case ExportTimeUnitSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	case ExportTimeUnitMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.UTC().Format(time.RFC3339)
+	}
+}
+
+type errInvalidParam string
+
+func (e errInvalidParam) Error() string {
+	return "invalid " + string(e) + " parameter"
+}
+
+// formatExportValue serializes a decoded WEMS value for a CSV cell, keeping
+// its decoded type so that large integers, strings and booleans are written
+// as received. Nulls, NaN and infinite values become empty cells, unless the
+// NonFiniteError policy of opts fails the export.
+func formatExportValue(value interface{}, t time.Time, opts convertOptions) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if err := opts.checkFinite(v, t); err != nil || isNonFinite(v) {
+			return "", err
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		if f, err := opts.parseNumber(v); err == nil && isNonFinite(f) {
+			return "", opts.checkFinite(f, t)
+		}
+		return v, nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// exportCSV serves the export-csv resource, returning the points of a series
+// as time,value rows, processed by seriesPoints like those of export-arrow.
+// Times are RFC 3339 unless timeUnit asks for Unix seconds or milliseconds.
+// Values are written by formatExportValue. Upstream errors follow
+// ErrorVerbosity like query errors.
+func (d *Datasource) exportCSV(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	qm, query, err := parseSeriesParams(req.URL)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(err.Error()),
+		})
+	}
+	unit, err := parseExportTimeUnit(req.URL)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(err.Error()),
+		})
+	}
+	points, _, err := d.seriesPoints(ctx, qm, query)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte(d.redact(d.errorText(err))),
+		})
+	}
+
+	opts := d.convertOptions()
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"time", "value"})
+	for _, p := range points {
+		t := time.Unix(p.Time, 0).UTC()
+		value, err := formatExportValue(p.Value, t, opts)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   []byte(err.Error()),
+			})
+		}
+		_ = w.Write([]string{formatExportTime(t, unit), value})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to write CSV: " + err.Error()),
+		})
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"text/csv"}},
+		Body:    buf.Bytes(),
+	})
+}
+
+// exportArrow serves the export-arrow resource, returning a series as the
+// frame a panel query would get, serialized in the Arrow IPC file format.
+func (d *Datasource) exportArrow(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	qm, query, err := parseSeriesParams(req.URL)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte(err.Error()),
+		})
+	}
+	frame, err := d.seriesFrame(ctx, qm, query)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte(d.redact(d.errorText(err))),
+		})
+	}
+	body, err := frame.MarshalArrow()
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to write Arrow: " + err.Error()),
+		})
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"application/vnd.apache.arrow.file"}},
+		Body:    body,
+	})
+}
@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestExportCSV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/endpoint/ep/series/app/svc/dp" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("from") != "1700000000" || r.URL.Query().Get("to") != "1700000060" {
+			t.Errorf("unexpected range %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1.5},{"time":1700000030,"value":"2"}]`))
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(srv.URL)
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-csv",
+		URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700000060",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if ct := res.Headers["Content-Type"]; len(ct) != 1 || ct[0] != "text/csv" {
+		t.Fatalf("unexpected content type %v", ct)
+	}
+	want := "time,value\n2023-11-14T22:13:20Z,1.5\n2023-11-14T22:13:50Z,2\n"
+	if string(res.Body) != want {
+		t.Fatalf("unexpected body:\n%s\nwant:\n%s", res.Body, want)
+	}
+}
+
+func TestExportCSVTimeUnit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1.5},{"time":1700000030,"value":2}]`))
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(srv.URL)
+	for unit, want := range map[string]string{
+		"s":  "time,value\n1700000000,1.5\n1700000030,2\n",
+		"ms": "time,value\n1700000000000,1.5\n1700000030000,2\n",
+	} {
+		res := callResource(t, ds, &backend.CallResourceRequest{
+			Path: "export-csv",
+			URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700000060&timeUnit=" + unit,
+		})
+		if res.Status != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", unit, res.Status, res.Body)
+		}
+		if string(res.Body) != want {
+			t.Errorf("%s: unexpected body:\n%s\nwant:\n%s", unit, res.Body, want)
+		}
+	}
+
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-csv",
+		URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700000060&timeUnit=us",
+	})
+	if res.Status != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d for invalid timeUnit", res.Status)
+	}
+}
+
+func TestExportCSVMissingRange(t *testing.T) {
+	ds := newTestDatasource("http://127.0.0.1:0")
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-csv",
+		URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp",
+	})
+	if res.Status != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", res.Status)
+	}
+}
+
+func TestExportCSVKeepsValueTypes(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":9007199254740993},{"time":1700000060,"value":"open"},{"time":1700000120,"value":true},{"time":1700000180,"value":null},{"time":1700000240,"value":0.25}]`)
+	ds := newTestDatasource(srv.URL)
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-csv",
+		URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700003600&timeUnit=s",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	want := "time,value\n1700000000,9007199254740993\n1700000060,open\n1700000120,true\n1700000180,\n1700000240,0.25\n"
+	if string(res.Body) != want {
+		t.Errorf("unexpected body:\n%s\nwant:\n%s", res.Body, want)
+	}
+}
+
+func TestExportErrorVerbosity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal db host db-7.wems.local unreachable", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ErrorVerbosity = ErrorVerbosityMinimal
+	for _, path := range []string{"export-csv", "export-arrow"} {
+		res := callResource(t, ds, &backend.CallResourceRequest{
+			Path: path,
+			URL:  path + "?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700003600",
+		})
+		if res.Status != http.StatusBadGateway || strings.Contains(string(res.Body), "db-7.wems.local") {
+			t.Errorf("%s: expected a generic error, got %d: %s", path, res.Status, res.Body)
+		}
+	}
+}
+
+func TestExportArrow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1.5},{"time":1700000030,"value":"2"}]`))
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(srv.URL)
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-arrow",
+		URL:  "export-arrow?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700000060",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if ct := res.Headers["Content-Type"]; len(ct) != 1 || ct[0] != "application/vnd.apache.arrow.file" {
+		t.Fatalf("unexpected content type %v", ct)
+	}
+	frame, err := data.UnmarshalArrowFrame(res.Body)
+	if err != nil {
+		t.Fatalf("invalid Arrow payload: %v", err)
+	}
+
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+	want, err := ds.seriesFrame(context.Background(), qm, backend.DataQuery{
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700000060, 0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Name != want.Name || len(frame.Fields) != len(want.Fields) || frame.Rows() != 2 {
+		t.Fatalf("expected frame %v, got %v", want, frame)
+	}
+	for i, field := range frame.Fields {
+		if field.Name != want.Fields[i].Name || field.Type() != want.Fields[i].Type() {
+			t.Errorf("field %d: expected %s %s, got %s %s", i, want.Fields[i].Name, want.Fields[i].Type(), field.Name, field.Type())
+			continue
+		}
+		for row := 0; row < field.Len(); row++ {
+			if got, exp := field.At(row), want.Fields[i].At(row); got != exp {
+				if gt, ok := got.(time.Time); !ok || !gt.Equal(exp.(time.Time)) {
+					t.Errorf("field %d row %d: expected %v, got %v", i, row, exp, got)
+				}
+			}
+		}
+	}
+	if again, err := frame.MarshalArrow(); err != nil || !bytes.Equal(again, res.Body) {
+		t.Errorf("expected the frame to round-trip to the same payload, err %v", err)
+	}
+}
+
+func TestExportCSVProcessesPointsLikeArrow(t *testing.T) {
+	srv := seriesServer(t, `[{"time":0,"value":9},{"time":1700000000,"value":1},{"time":1700000030,"value":2},{"time":1700000060,"value":3}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxPoints = 2
+	ds.settings.OnMaxPoints = MaxPointsTruncate
+
+	res := callResource(t, ds, &backend.CallResourceRequest{
+		Path: "export-csv",
+		URL:  "export-csv?endpointId=ep&applianceId=app&serviceUri=svc&datapoint=dp&from=1700000000&to=1700000060&timeUnit=s",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	want := "time,value\n1700000000,1\n1700000030,2\n"
+	if string(res.Body) != want {
+		t.Errorf("expected the zero time dropped and the points capped:\nwant %q\ngot  %q", want, res.Body)
+	}
+}
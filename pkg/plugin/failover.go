@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// baseURLList is a list of base URLs that can be given in the settings either
+// as a JSON array or as a single comma-separated string.
+type baseURLList []string
+
+func (l *baseURLList) UnmarshalJSON(b []byte) error {
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		var joined string
+		if err := json.Unmarshal(b, &joined); err != nil {
+			return err
+		}
+		list = strings.Split(joined, ",")
+	}
+	*l = (*l)[:0]
+	for _, u := range list {
+		if u = strings.TrimSpace(u); u != "" {
+			*l = append(*l, u)
+		}
+	}
+	return nil
+}
+
+// currentBaseURL returns the base URL last known to be reachable.
+func (d *Datasource) currentBaseURL() string {
+	if len(d.baseURLs) == 0 {
+		return d.baseURL
+	}
+	return d.baseURLs[int(d.activeBaseURL.Load())%len(d.baseURLs)]
+}
+
+// doWithFailover sends the request built by newReq, trying each configured
+// base URL in turn, starting with the one last known to be healthy, until one
+// can be reached. Only connection failures trigger failover; any HTTP
+// response, whatever its status, is returned to the caller.
+func (d *Datasource) doWithFailover(client *http.Client, newReq func(baseURL string) (*http.Request, error)) (*http.Response, error) {
+	urls := d.baseURLs
+	if len(urls) == 0 {
+		urls = []string{d.baseURL}
+	}
+	start := int(d.activeBaseURL.Load()) % len(urls)
+	var lastErr error
+	for i := range urls {
+		idx := (start + i) % len(urls)
+		req, err := newReq(urls[idx])
+		if err != nil {
+			return nil, err
+		}
+		resp, err := d.send(client, req)
+		if err == nil {
+			if idx != start {
+				log.DefaultLogger.Warn("Failed over to WEMS base URL", "baseURL", urls[idx])
+				d.activeBaseURL.Store(int32(idx))
+			}
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestBaseURLListUnmarshal(t *testing.T) {
+	for _, raw := range []string{`"http://a, http://b"`, `["http://a","http://b"]`} {
+		var l baseURLList
+		if err := json.Unmarshal([]byte(raw), &l); err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 2 || l[0] != "http://a" || l[1] != "http://b" {
+			t.Errorf("%s: unexpected list %v", raw, l)
+		}
+	}
+}
+
+func TestFailoverToSecondaryBaseURL(t *testing.T) {
+	// A closed server refuses connections, simulating an unreachable primary.
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primaryURL := primary.URL
+	primary.Close()
+
+	var secondaryCalls atomic.Int32
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryCalls.Add(1)
+		if r.URL.Path == "/v1/token" {
+			_, _ = w.Write([]byte("token"))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer secondary.Close()
+
+	inst, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"client_id":"id","base_urls":"` + primaryURL + `,` + secondary.URL + `/"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := inst.(*Datasource)
+	if got := ds.currentBaseURL(); got != secondary.URL {
+		t.Fatalf("expected secondary to be active, got %s", got)
+	}
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if secondaryCalls.Load() != 2 {
+		t.Errorf("expected token and series requests on secondary, got %d", secondaryCalls.Load())
+	}
+}
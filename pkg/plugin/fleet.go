@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/errgroup"
+)
+
+// QueryTypeFleetStatus is the query type of fleet status queries, which
+// return the name and online state of several endpoints.
+const QueryTypeFleetStatus = "fleet-status"
+
+// fleetStatusConcurrency caps the number of endpoint descriptions a fleet
+// status query fetches at the same time.
+const fleetStatusConcurrency = 8
+
+// isFleetStatusQuery reports whether query is a fleet status query.
+func isFleetStatusQuery(query backend.DataQuery) bool {
+	return strings.EqualFold(query.QueryType, QueryTypeFleetStatus)
+}
+
+// fleetEndpoint is a row of the fleet status frame.
+type fleetEndpoint struct {
+	ID     string
+	Name   string
+	Online *bool
+}
+
+// fleetStatusFrame fetches the descriptions of endpointIDs, or of all
+// endpoints if empty, concurrently and returns one row per endpoint with its
+// ID, name and online state. An endpoint whose description cannot be fetched
+// keeps the values of the endpoint list, if any, and adds a notice.
+func (d *Datasource) fleetStatusFrame(ctx context.Context, endpointIDs []string) (*data.Frame, error) {
+	var endpoints []fleetEndpoint
+	if len(endpointIDs) == 0 {
+		listed, err := d.listFleetEndpoints(ctx)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = listed
+	} else {
+		for _, id := range endpointIDs {
+			if id = strings.TrimSpace(id); id != "" {
+				endpoints = append(endpoints, fleetEndpoint{ID: id, Name: id})
+			}
+		}
+	}
+
+	errs := make([]error, len(endpoints))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(fleetStatusConcurrency)
+	for i := range endpoints {
+		g.Go(func() error {
+			errs[i] = d.describeFleetEndpoint(gctx, &endpoints[i])
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	ids := make([]string, len(endpoints))
+	names := make([]string, len(endpoints))
+	online := make([]*bool, len(endpoints))
+	for i, ep := range endpoints {
+		ids[i], names[i], online[i] = ep.ID, ep.Name, ep.Online
+	}
+	frame := data.NewFrame("fleet-status",
+		data.NewField("endpoint_id", nil, ids),
+		data.NewField("name", nil, names),
+		data.NewField("online", nil, online),
+	)
+	for i, err := range errs {
+		if err != nil {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityError,
+				Text:     fmt.Sprintf("endpoint %s: %s", endpoints[i].ID, d.errorText(err)),
+			})
+		}
+	}
+	return frame, nil
+}
+
+// listFleetEndpoints returns all endpoints with the name and online state of
+// the endpoint list.
+func (d *Datasource) listFleetEndpoints(ctx context.Context) ([]fleetEndpoint, error) {
+	body, errResp := d.getResourceBody(ctx, d.currentBaseURL()+"/v1/endpoint/")
+	if errResp != nil {
+		return nil, fmt.Errorf("endpoint list: status %d: %s", errResp.Status, errResp.Body)
+	}
+	var listed []struct {
+		EndpointID   string `json:"endpointId"`
+		FriendlyName string `json:"friendlyName"`
+		Online       *bool  `json:"online"`
+	}
+	if err := json.Unmarshal(body, &listed); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoints: %w", err)
+	}
+	endpoints := make([]fleetEndpoint, 0, len(listed))
+	for _, ep := range listed {
+		name := ep.FriendlyName
+		if name == "" {
+			name = ep.EndpointID
+		}
+		endpoints = append(endpoints, fleetEndpoint{ID: ep.EndpointID, Name: name, Online: ep.Online})
+	}
+	return endpoints, nil
+}
+
+// describeFleetEndpoint updates ep with the name and online state reported
+// by its description.
+func (d *Datasource) describeFleetEndpoint(ctx context.Context, ep *fleetEndpoint) error {
+	body, errResp := d.getResourceBody(ctx, d.endpointDescriptionURL(ep.ID, "false", "false"))
+	if errResp != nil {
+		return fmt.Errorf("status %d: %s", errResp.Status, errResp.Body)
+	}
+	var desc struct {
+		FriendlyName string `json:"friendlyName"`
+		Online       *bool  `json:"online"`
+	}
+	if err := json.Unmarshal(body, &desc); err != nil {
+		return fmt.Errorf("failed to parse description: %w", err)
+	}
+	if desc.FriendlyName != "" {
+		ep.Name = desc.FriendlyName
+	}
+	if desc.Online != nil {
+		ep.Online = desc.Online
+	}
+	return nil
+}
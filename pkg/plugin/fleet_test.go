@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestFleetStatusQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/endpoint/":
+			_, _ = w.Write([]byte(`[
+				{"endpointId":"ep1","online":false},
+				{"endpointId":"ep2","friendlyName":"Plant B","online":true},
+				{"endpointId":"ep3"}
+			]`))
+		case "/v1/endpoint/ep1/description":
+			_, _ = w.Write([]byte(`{"friendlyName":"Plant A","online":true,"processes":[]}`))
+		case "/v1/endpoint/ep2/description":
+			http.Error(w, "gateway down", http.StatusBadGateway)
+		case "/v1/endpoint/ep3/description":
+			_, _ = w.Write([]byte(`{"processes":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	fleet := func(model string) backend.DataResponse {
+		return ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID:     "A",
+			QueryType: QueryTypeFleetStatus,
+			JSON:      []byte(model),
+		})
+	}
+	res := fleet(`{}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 3 {
+		t.Fatalf("expected 3 endpoints, got %d", frame.Rows())
+	}
+	for i, want := range []struct {
+		id, name string
+		online   *bool
+	}{
+		{"ep1", "Plant A", ptr(true)},
+		{"ep2", "Plant B", ptr(true)}, // description failed, list values kept
+		{"ep3", "ep3", nil},
+	} {
+		online := frame.Fields[2].At(i).(*bool)
+		if frame.Fields[0].At(i) != want.id || frame.Fields[1].At(i) != want.name ||
+			(online == nil) != (want.online == nil) || (online != nil && *online != *want.online) {
+			t.Errorf("row %d: expected %s/%s/%v, got %v/%v/%v", i, want.id, want.name, want.online,
+				frame.Fields[0].At(i), frame.Fields[1].At(i), online)
+		}
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "endpoint ep2") {
+		t.Errorf("expected a notice for ep2, got %+v", frame.Meta)
+	}
+
+	res = fleet(`{"endpoint_ids":["ep3","ep1"]}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame = res.Frames[0]
+	if frame.Rows() != 2 || frame.Fields[0].At(0) != "ep3" || frame.Fields[1].At(1) != "Plant A" {
+		t.Errorf("expected only the listed endpoints in order, got %d rows", frame.Rows())
+	}
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Response layouts, see DatasourceSettings.FrameFormat.
+const (
+	FrameFormatLong = "long"
+	FrameFormatWide = "wide"
+)
+
+// parseJoinTolerance validates the join_tolerance of qm and returns it. Zero
+// means timestamps must match exactly.
+func parseJoinTolerance(qm WEMSQueryModel) (time.Duration, error) {
+	if qm.JoinTolerance == "" {
+		return 0, nil
+	}
+	tolerance, err := time.ParseDuration(qm.JoinTolerance)
+	if err != nil {
+		return 0, fmt.Errorf("invalid join_tolerance %q: %w", qm.JoinTolerance, err)
+	}
+	if tolerance < 0 {
+		return 0, fmt.Errorf("invalid join_tolerance %q: must not be negative", qm.JoinTolerance)
+	}
+	return tolerance, nil
+}
+
+// joinWide outer-joins frames made of a time field followed by value fields
+// into a single wide time series frame. Value fields become nullable and are
+// null at times where their series has no point. Timestamps up to tolerance
+// after the first timestamp of a row share that row, so series sampled a few
+// seconds apart align; a series with several points in a row keeps the last.
+func joinWide(frames []*data.Frame, tolerance time.Duration) (*data.Frame, error) {
+	var times []time.Time
+	for _, f := range frames {
+		if len(f.Fields) == 0 || f.Fields[0].Type() != data.FieldTypeTime {
+			return nil, fmt.Errorf("frame %q has no leading time field", f.Name)
+		}
+		for i := 0; i < f.Fields[0].Len(); i++ {
+			times = append(times, f.Fields[0].At(i).(time.Time))
+		}
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	times = slices.CompactFunc(times, time.Time.Equal)
+	row := make(map[int64]int, len(times))
+	var rowTimes []time.Time
+	for _, t := range times {
+		if len(rowTimes) == 0 || t.Sub(rowTimes[len(rowTimes)-1]) > tolerance {
+			rowTimes = append(rowTimes, t)
+		}
+		row[t.UnixNano()] = len(rowTimes) - 1
+	}
+	times = rowTimes
+
+	wide := data.NewFrame("", data.NewField("time", nil, times))
+	wide.SetMeta(&data.FrameMeta{
+		Type:        data.FrameTypeTimeSeriesWide,
+		TypeVersion: data.FrameTypeVersion{0, 1},
+	})
+	if len(frames) == 1 {
+		wide.Name = frames[0].Name
+	}
+	for _, f := range frames {
+		for _, src := range f.Fields[1:] {
+			dst := data.NewFieldFromFieldType(src.Type().NullableType(), len(times))
+			dst.Name = src.Name
+			dst.Labels = src.Labels
+			dst.Config = src.Config
+			for i := 0; i < src.Len(); i++ {
+				v, ok := src.ConcreteAt(i)
+				if !ok {
+					continue
+				}
+				dst.SetConcrete(row[f.Fields[0].At(i).(time.Time).UnixNano()], v)
+			}
+			wide.Fields = append(wide.Fields, dst)
+		}
+		if f.Meta != nil && len(f.Meta.Notices) > 0 {
+			wide.AppendNotices(f.Meta.Notices...)
+		}
+	}
+	return wide, nil
+}
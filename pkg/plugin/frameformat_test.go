@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryFrameFormat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The detail request of a multi-resolution query starts later and
+		// gets a different point set than the overview.
+		if r.URL.Query().Get("from") == "1700000000" {
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1},{"time":1700001800,"value":2}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700001800,"value":3},{"time":1700003000,"value":4}]`))
+	}))
+	defer srv.Close()
+	query := backend.DataQuery{
+		RefID:     "A",
+		JSON:      []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","multi_resolution":true}`),
+		Interval:  time.Minute,
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)},
+	}
+
+	ds := newTestDatasource(srv.URL)
+	ds.settings.FrameFormat = FrameFormatLong
+	res := ds.query(context.Background(), backend.PluginContext{}, query)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("long: expected one frame per series, got %d", len(res.Frames))
+	}
+	for _, f := range res.Frames {
+		if f.Meta != nil && f.Meta.Type != "" {
+			t.Errorf("long: expected no frame type, got %s", f.Meta.Type)
+		}
+	}
+
+	ds.settings.FrameFormat = FrameFormatWide
+	res = ds.query(context.Background(), backend.PluginContext{}, query)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("wide: expected a single frame, got %d", len(res.Frames))
+	}
+	wide := res.Frames[0]
+	if wide.Meta == nil || wide.Meta.Type != data.FrameTypeTimeSeriesWide {
+		t.Fatalf("wide: unexpected meta %+v", wide.Meta)
+	}
+	if len(wide.Fields) != 3 || wide.Rows() != 3 {
+		t.Fatalf("wide: expected 3 fields x 3 rows, got %d x %d", len(wide.Fields), wide.Rows())
+	}
+	overview, detail := wide.Fields[1], wide.Fields[2]
+	if overview.Labels["resolution"] != "overview" || detail.Labels["resolution"] != "detail" {
+		t.Errorf("wide: labels not preserved: %v %v", overview.Labels, detail.Labels)
+	}
+	// Rows: 1700000000 (overview only), 1700001800 (both), 1700003000 (detail only)
+	if v, ok := overview.ConcreteAt(2); ok {
+		t.Errorf("wide: expected null overview at last row, got %v", v)
+	}
+	if v, _ := detail.ConcreteAt(1); v != 3.0 {
+		t.Errorf("wide: expected detail 3 at shared time, got %v", v)
+	}
+}
+
+func TestQueryWideJoinTolerance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/temp") {
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1},{"time":1700000060,"value":2},{"time":1700000120,"value":3}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000002,"value":10},{"time":1700000063,"value":20},{"time":1700000130,"value":30}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.FrameFormat = FrameFormatWide
+	targets := `"targets":[{"service_uri":"svc","data_point":"temp"},{"service_uri":"svc","data_point":"hum"}]`
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app",`+targets+`}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if rows := res.Frames[0].Rows(); rows != 6 {
+		t.Fatalf("exact join: expected 6 rows, got %d", rows)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","join_tolerance":"5s",`+targets+`}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	wide := res.Frames[0]
+	// The third hum point is 10s off and gets its own row.
+	if wide.Rows() != 4 {
+		t.Fatalf("tolerance join: expected 4 rows, got %d", wide.Rows())
+	}
+	want := []struct {
+		time      int64
+		temp, hum *float64
+	}{
+		{1700000000, ptr(1.0), ptr(10.0)},
+		{1700000060, ptr(2.0), ptr(20.0)},
+		{1700000120, ptr(3.0), nil},
+		{1700000130, nil, ptr(30.0)},
+	}
+	for i, w := range want {
+		if got := wide.Fields[0].At(i).(time.Time).Unix(); got != w.time {
+			t.Errorf("row %d: expected time %d, got %d", i, w.time, got)
+		}
+		for j, wv := range []*float64{w.temp, w.hum} {
+			v, ok := wide.Fields[j+1].ConcreteAt(i)
+			if ok != (wv != nil) || (ok && v != *wv) {
+				t.Errorf("row %d field %d: expected %v, got %v", i, j+1, wv, v)
+			}
+		}
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","join_tolerance":"soon",`+targets+`}`)
+	if res.Status != backend.StatusBadRequest {
+		t.Errorf("expected bad request for invalid join_tolerance, got %v", res.Status)
+	}
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// healthDetails is the JSONDetails payload of a health check result. Errors
+// and URLs are redacted.
+type healthDetails struct {
+	BaseURL     string     `json:"baseUrl"`
+	TokenExpiry *time.Time `json:"tokenExpiry,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	// Endpoints and ReachableEndpoints count the endpoints WEMS lists and
+	// those of them that are online. They are left out if the list could
+	// not be fetched.
+	Endpoints          *int `json:"endpoints,omitempty"`
+	ReachableEndpoints *int `json:"reachableEndpoints,omitempty"`
+}
+
+// healthDetails builds the JSONDetails of a health check that failed with
+// err, or that passed if err is nil. A passed check also counts the endpoints;
+// failing to list them is reported as the last error without failing the
+// check.
+func (d *Datasource) healthDetails(ctx context.Context, err error) []byte {
+	details := healthDetails{BaseURL: d.redact(d.currentBaseURL())}
+	d.mutex.Lock()
+	if d.token != "" {
+		expiry := d.tokenExpiry.UTC()
+		details.TokenExpiry = &expiry
+	}
+	if err == nil {
+		err = d.tokenErr
+	}
+	d.mutex.Unlock()
+
+	if err == nil {
+		var endpoints []struct {
+			Online bool `json:"online"`
+		}
+		body, errResp := d.getResourceBody(ctx, d.currentBaseURL()+"/v1/endpoint/")
+		switch {
+		case errResp != nil:
+			details.LastError = d.redact("Failed to list endpoints: " + string(errResp.Body))
+		case json.Unmarshal(body, &endpoints) != nil:
+			details.LastError = "Failed to parse endpoints"
+		default:
+			reachable := 0
+			for _, ep := range endpoints {
+				if ep.Online {
+					reachable++
+				}
+			}
+			total := len(endpoints)
+			details.Endpoints, details.ReachableEndpoints = &total, &reachable
+		}
+	} else {
+		details.LastError = d.redact(err.Error())
+	}
+	b, _ := json.Marshal(details)
+	return b
+}
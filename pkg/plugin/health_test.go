@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCheckHealthJSONDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/token" {
+			_, _ = w.Write([]byte("fresh-token"))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"endpointId":"ep1","online":true},{"endpointId":"ep2","online":false},{"endpointId":"ep3","online":true}]`))
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL, clientSecret: "s3cret"}
+	ds.settings.ClientID, ds.settings.ClientSecret = "id", "s3cret"
+	ds.settings.BaseURL = srv.URL
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusOk {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	var details healthDetails
+	if err := json.Unmarshal(res.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.BaseURL != srv.URL || details.LastError != "" {
+		t.Errorf("unexpected details %+v", details)
+	}
+	if details.TokenExpiry == nil || !details.TokenExpiry.After(time.Now()) {
+		t.Errorf("expected a future token expiry, got %v", details.TokenExpiry)
+	}
+	if details.Endpoints == nil || *details.Endpoints != 3 || details.ReachableEndpoints == nil || *details.ReachableEndpoints != 2 {
+		t.Errorf("expected 2 of 3 endpoints reachable, got %+v", details)
+	}
+}
+
+func TestCheckHealthJSONDetailsRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown client secret s3cret", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL, clientSecret: "s3cret"}
+	ds.settings.ClientID, ds.settings.ClientSecret = "id", "s3cret"
+	ds.settings.BaseURL = srv.URL
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var details healthDetails
+	if err := json.Unmarshal(res.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(details.LastError, "unknown client secret") || strings.Contains(string(res.JSONDetails), "s3cret") {
+		t.Errorf("expected a redacted last error, got %s", res.JSONDetails)
+	}
+	if details.Endpoints != nil || details.TokenExpiry != nil {
+		t.Errorf("expected no endpoint count or expiry without a token, got %+v", details)
+	}
+}
+
+func TestCheckHealthSoftFail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	check := func(baseURL string, softFail bool) *backend.CheckHealthResult {
+		t.Helper()
+		ds := &Datasource{baseURL: baseURL, clientID: "id", clientSecret: "secret"}
+		ds.settings.ClientID, ds.settings.ClientSecret, ds.settings.BaseURL = "id", "secret", baseURL
+		ds.settings.HealthCheckSoftFail = softFail
+		res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := check(unreachable.URL, false); res.Status != backend.HealthStatusError {
+		t.Errorf("expected an unreachable WEMS to fail by default, got %+v", res)
+	}
+	res := check(unreachable.URL, true)
+	if res.Status != backend.HealthStatusOk || !strings.HasPrefix(res.Message, "Warning: Cannot reach WEMS") {
+		t.Errorf("expected soft fail to pass with a warning, got %+v", res)
+	}
+	if len(res.JSONDetails) == 0 {
+		t.Error("expected JSON details on soft fail")
+	}
+	if res := check(srv.URL, true); res.Status != backend.HealthStatusError {
+		t.Errorf("expected rejected credentials to fail despite soft fail, got %+v", res)
+	}
+}
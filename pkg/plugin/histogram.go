@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultHistogramBuckets is the number of buckets of a histogram query
+// without Buckets.
+const defaultHistogramBuckets = 10
+
+// maxHistogramBuckets caps Buckets.
+const maxHistogramBuckets = 1000
+
+// validateHistogram checks the bucket count of a histogram query.
+func validateHistogram(qm WEMSQueryModel) error {
+	if qm.Buckets < 0 || qm.Buckets > maxHistogramBuckets {
+		return fmt.Errorf("invalid buckets %d: must be between 0 (default) and %d", qm.Buckets, maxHistogramBuckets)
+	}
+	return nil
+}
+
+// histogramFrames returns a histogram of the values of the series for qm.
+func (d *Datasource) histogramFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	points, notices, err := d.seriesPoints(ctx, qm, query)
+	if err != nil {
+		return nil, err
+	}
+	buckets := qm.Buckets
+	if buckets == 0 {
+		buckets = defaultHistogramBuckets
+	}
+	name := d.seriesName(qm.EndpointID, qm.ApplianceID, qm.ServiceURI, qm.DataPoint)
+	frame, err := d.histogramFrame(name, d.unitFor(qm), points, buckets)
+	if err != nil {
+		return nil, err
+	}
+	frame.AppendNotices(notices...)
+	return []*data.Frame{frame}, nil
+}
+
+// histogramFrame splits the range between the smallest and largest value of
+// points into equally wide buckets and counts the values in each, as xMin,
+// xMax and count fields the histogram panel understands. The largest value
+// falls into the last bucket; a series with a single distinct value gets a
+// single bucket. Null, NaN and infinite values are skipped, unless OnNonFinite
+// fails the query. Like a summary frame, the frame is typed as numeric wide.
+func (d *Datasource) histogramFrame(name, unit string, points []TimeSeriesDataPoint, buckets int) (*data.Frame, error) {
+	var present []TimeSeriesDataPoint
+	for _, p := range points {
+		if p.Value != nil {
+			present = append(present, p)
+		}
+	}
+	_, values, err := convertPoints(present, d.convertOptions())
+	if err != nil {
+		return nil, err
+	}
+	values = slices.DeleteFunc(values, isNonFinite)
+
+	var lower, upper []float64
+	var counts []int64
+	if len(values) > 0 {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		if lo == hi {
+			buckets = 1
+		}
+		width := (hi - lo) / float64(buckets)
+		lower, upper, counts = make([]float64, buckets), make([]float64, buckets), make([]int64, buckets)
+		for i := range buckets {
+			lower[i] = lo + float64(i)*width
+			upper[i] = lo + float64(i+1)*width
+		}
+		upper[buckets-1] = hi
+		for _, v := range values {
+			i := buckets - 1
+			if width > 0 {
+				i = min(int((v-lo)/width), buckets-1)
+			}
+			counts[i]++
+		}
+	}
+
+	frame := data.NewFrame(name+" histogram",
+		data.NewField("xMin", nil, lower),
+		data.NewField("xMax", nil, upper),
+		data.NewField("count", nil, counts),
+	)
+	if unit != "" {
+		frame.Fields[0].Config = &data.FieldConfig{Unit: unit}
+		frame.Fields[1].Config = &data.FieldConfig{Unit: unit}
+	}
+	frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericWide})
+	return frame, nil
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestHistogramQuery(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":0},{"time":1700000060,"value":1},{"time":1700000120,"value":2.5},`+
+		`{"time":1700000180,"value":4},{"time":1700000240,"value":6},{"time":1700000300,"value":9},{"time":1700000360,"value":10},`+
+		`{"time":1700000420,"value":null}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","histogram":true,"buckets":4,"unit":"kW"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("expected a single histogram frame, got %d", len(res.Frames))
+	}
+	frame := res.Frames[0]
+	if len(frame.Fields) != 3 || frame.Fields[0].Name != "xMin" || frame.Fields[1].Name != "xMax" || frame.Fields[2].Name != "count" {
+		t.Fatalf("unexpected fields %v", frame.Fields)
+	}
+	// Buckets of width 2.5 over [0, 10]; 10 falls into the last bucket.
+	wantMin := []float64{0, 2.5, 5, 7.5}
+	wantMax := []float64{2.5, 5, 7.5, 10}
+	wantCount := []int64{2, 2, 1, 2}
+	for i := range wantCount {
+		if got := frame.Fields[0].At(i).(float64); got != wantMin[i] {
+			t.Errorf("bucket %d: expected xMin %v, got %v", i, wantMin[i], got)
+		}
+		if got := frame.Fields[1].At(i).(float64); got != wantMax[i] {
+			t.Errorf("bucket %d: expected xMax %v, got %v", i, wantMax[i], got)
+		}
+		if got := frame.Fields[2].At(i).(int64); got != wantCount[i] {
+			t.Errorf("bucket %d: expected count %d, got %d", i, wantCount[i], got)
+		}
+	}
+	if frame.Fields[0].Config == nil || frame.Fields[0].Config.Unit != "kW" {
+		t.Errorf("expected unit on bucket bounds, got %+v", frame.Fields[0].Config)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","histogram":true,"buckets":-1}`)
+	if res.Error == nil {
+		t.Error("expected error for negative buckets")
+	}
+}
+
+func TestHistogramSingleValue(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	frame, err := ds.histogramFrame("s", "", []TimeSeriesDataPoint{{Time: 1, Value: 3.0}, {Time: 2, Value: 3.0}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 1 || frame.Fields[2].At(0).(int64) != 2 {
+		t.Errorf("expected a single bucket with 2 values, got %d rows", frame.Rows())
+	}
+}
+
+func TestHistogramSkipsNonFiniteValues(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	frame, err := ds.histogramFrame("s", "", []TimeSeriesDataPoint{
+		{Time: 1, Value: 0.0}, {Time: 2, Value: "Inf"}, {Time: 3, Value: "-Inf"}, {Time: 4, Value: "NaN"}, {Time: 5, Value: 4.0},
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 2 {
+		t.Fatalf("expected 2 buckets, got %d", frame.Rows())
+	}
+	for i, want := range []float64{0, 2} {
+		if got := frame.Fields[0].At(i).(float64); got != want {
+			t.Errorf("bucket %d: expected xMin %v, got %v", i, want, got)
+		}
+		if got := frame.Fields[2].At(i).(int64); got != 1 {
+			t.Errorf("bucket %d: expected count 1, got %d", i, got)
+		}
+	}
+
+	frame, err = ds.histogramFrame("s", "", []TimeSeriesDataPoint{{Time: 1, Value: "NaN"}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 0 {
+		t.Errorf("expected no buckets without finite values, got %d", frame.Rows())
+	}
+}
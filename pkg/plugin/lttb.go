@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// lttbPoints reduces points to threshold points with the
+// largest-triangle-three-buckets algorithm, which keeps the visual shape of
+// the series. The first and last points are always kept. Series with at most
+// threshold points, or thresholds below 3, are returned unchanged. Values are
+// compared after numeric conversion with opts; the kept points are returned
+// as they were. Null points are never picked over a point with a value, so
+// gaps do not count as zeros; a bucket of only nulls keeps its first point to
+// preserve the gap.
+func lttbPoints(points []TimeSeriesDataPoint, threshold int, opts convertOptions) []TimeSeriesDataPoint {
+	if threshold < 3 || len(points) <= threshold {
+		return points
+	}
+	if !slices.IsSortedFunc(points, comparePointTimes) {
+		points = slices.Clone(points)
+		slices.SortStableFunc(points, comparePointTimes)
+	}
+	value := func(i int) (float64, bool) {
+		if points[i].Value == nil {
+			return 0, false
+		}
+		v, _ := convertValue(points[i].Value, opts)
+		return v, true
+	}
+
+	result := make([]TimeSeriesDataPoint, 0, threshold)
+	result = append(result, points[0])
+	// The points between the first and last one are split into
+	// threshold-2 buckets; each contributes the point forming the largest
+	// triangle with the previously kept point and the next bucket's average.
+	// a is the last kept point with a value, if any.
+	every := float64(len(points)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		avgStart := int(math.Floor(float64(i+1)*every)) + 1
+		avgEnd := min(int(math.Floor(float64(i+2)*every))+1, len(points))
+		var avgX, avgY, n float64
+		for j := avgStart; j < avgEnd; j++ {
+			if v, ok := value(j); ok {
+				avgX += float64(points[j].Time)
+				avgY += v
+				n++
+			}
+		}
+		ax := float64(points[a].Time)
+		ay, aOK := value(a)
+		if n > 0 {
+			avgX, avgY = avgX/n, avgY/n
+		} else {
+			avgX, avgY = float64(points[avgEnd-1].Time), ay
+		}
+		if !aOK {
+			ay = avgY
+		}
+
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+		maxArea, next := -1.0, -1
+		for j := start; j < end; j++ {
+			v, ok := value(j)
+			if !ok {
+				continue
+			}
+			area := math.Abs((ax-avgX)*(v-ay) - (ax-float64(points[j].Time))*(avgY-ay))
+			if area > maxArea {
+				maxArea, next = area, j
+			}
+		}
+		if next < 0 {
+			result = append(result, points[start])
+			continue
+		}
+		result = append(result, points[next])
+		a = next
+	}
+	return append(result, points[len(points)-1])
+}
+
+func comparePointTimes(a, b TimeSeriesDataPoint) int {
+	return cmp.Compare(a.Time, b.Time)
+}
@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLTTBPoints(t *testing.T) {
+	points := make([]TimeSeriesDataPoint, 1000)
+	for i := range points {
+		points[i] = TimeSeriesDataPoint{Time: int64(i), Value: math.Sin(float64(i) / 50)}
+	}
+	// A single spike must survive decimation.
+	points[500].Value = 10.0
+
+	got := lttbPoints(points, 100, convertOptions{})
+	if len(got) != 100 {
+		t.Fatalf("expected 100 points, got %d", len(got))
+	}
+	if got[0].Time != 0 || got[99].Time != 999 {
+		t.Errorf("expected first and last point to be kept, got %d and %d", got[0].Time, got[99].Time)
+	}
+	spike := false
+	for i, p := range got {
+		if i > 0 && p.Time <= got[i-1].Time {
+			t.Fatalf("points out of order at %d", i)
+		}
+		if p.Time == 500 {
+			spike = true
+		}
+	}
+	if !spike {
+		t.Error("expected the spike to be kept")
+	}
+
+	if got := lttbPoints(points[:50], 100, convertOptions{}); len(got) != 50 {
+		t.Errorf("expected short series unchanged, got %d points", len(got))
+	}
+}
+
+func TestLTTBPointsSkipsNulls(t *testing.T) {
+	points := make([]TimeSeriesDataPoint, 1000)
+	for i := range points {
+		points[i] = TimeSeriesDataPoint{Time: int64(i), Value: 100 + math.Sin(float64(i)/50)}
+		// Scattered gaps, plus one long outage.
+		if i%10 == 5 || (i >= 700 && i < 800) {
+			points[i].Value = nil
+		}
+	}
+	points[500].Value = 200.0
+
+	got := lttbPoints(points, 100, convertOptions{})
+	if len(got) != 100 {
+		t.Fatalf("expected 100 points, got %d", len(got))
+	}
+	spike, outage := false, false
+	for _, p := range got {
+		switch {
+		case p.Time == 500:
+			spike = true
+		case p.Time >= 700 && p.Time < 800:
+			outage = outage || p.Value == nil
+		case p.Value == nil:
+			t.Errorf("expected null at %d not to be picked over points with values", p.Time)
+		}
+	}
+	if !spike {
+		t.Error("expected the spike to be kept")
+	}
+	if !outage {
+		t.Error("expected the outage to be kept as a gap")
+	}
+}
+
+func TestQueryLTTB(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < 500; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"time":%d,"value":%d}`, 1700000000+i, i%7)
+	}
+	sb.WriteString("]")
+	ds := newTestDatasource(seriesServer(t, sb.String()).URL)
+
+	for _, tc := range []struct {
+		lttb bool
+		want int
+	}{{true, 50}, {false, 500}} {
+		res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID:         "A",
+			JSON:          []byte(fmt.Sprintf(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","lttb":%v}`, tc.lttb)),
+			TimeRange:     backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700003600, 0)},
+			MaxDataPoints: 50,
+		})
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got := res.Frames[0].Fields[1].Len(); got != tc.want {
+			t.Errorf("lttb=%v: expected %d points, got %d", tc.lttb, tc.want, got)
+		}
+	}
+}
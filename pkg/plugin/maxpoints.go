@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DefaultMaxPoints is the default cap on the number of points of a decoded
+// series.
+const DefaultMaxPoints = 1_000_000
+
+// Behaviours for series exceeding the point cap, see DatasourceSettings.OnMaxPoints.
+const (
+	// MaxPointsTruncate keeps the first points and attaches a warning.
+	MaxPointsTruncate = "truncate"
+	// MaxPointsError fails the query.
+	MaxPointsError = "error"
+)
+
+func (d *Datasource) maxPoints() int {
+	if d.settings.MaxPoints > 0 {
+		return d.settings.MaxPoints
+	}
+	return DefaultMaxPoints
+}
+
+// limitPoints enforces the point cap on a decoded series. When truncating, it
+// returns the kept points together with the warning to attach to the frame.
+func (d *Datasource) limitPoints(points []TimeSeriesDataPoint) ([]TimeSeriesDataPoint, *data.Notice, error) {
+	limit := d.maxPoints()
+	if len(points) <= limit {
+		return points, nil, nil
+	}
+	if d.settings.OnMaxPoints == MaxPointsError {
+		return nil, nil, fmt.Errorf("series has %d points, more than the maximum of %d", len(points), limit)
+	}
+	return points[:limit], &data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Series truncated to %d of %d points", limit, len(points)),
+	}, nil
+}
+
+// limitNotice returns a warning if LimitNotice is enabled and a series of n
+// points hit the limit sent to WEMS, meaning WEMS probably returned only part
+// of the range.
+func (d *Datasource) limitNotice(n, limit int) *data.Notice {
+	if !d.settings.LimitNotice || limit <= 0 || n < limit {
+		return nil
+	}
+	return &data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Series reached the limit of %d points and may be incomplete; narrow the time range or raise the limit", limit),
+	}
+}
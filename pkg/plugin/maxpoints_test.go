@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryMaxPointsTruncates(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700000060,"value":2},{"time":1700000120,"value":3}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxPoints = 2
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if n := frame.Fields[1].Len(); n != 2 {
+		t.Fatalf("expected 2 points, got %d", n)
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "2 of 3") {
+		t.Fatalf("expected truncation notice, got %+v", frame.Meta)
+	}
+
+	ds.settings.OnMaxPoints = MaxPointsError
+	if res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`); res.Error == nil {
+		t.Fatal("expected error for series over the maximum")
+	}
+}
+
+func TestQueryLimitNotice(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700000060,"value":2},{"time":1700000120,"value":3}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.DefaultLimit = 3
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	noticesOf := func() []data.Notice {
+		t.Helper()
+		res := runQuery(ds, model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if meta := res.Frames[0].Meta; meta != nil {
+			return meta.Notices
+		}
+		return nil
+	}
+
+	if notices := noticesOf(); len(notices) != 0 {
+		t.Fatalf("expected no notice with limit_notice off, got %+v", notices)
+	}
+	ds.settings.LimitNotice = true
+	if notices := noticesOf(); len(notices) != 1 || !strings.Contains(notices[0].Text, "limit of 3 points") {
+		t.Fatalf("expected limit notice at the boundary, got %+v", notices)
+	}
+	ds.settings.DefaultLimit = 4
+	if notices := noticesOf(); len(notices) != 0 {
+		t.Fatalf("expected no notice below the limit, got %+v", notices)
+	}
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// multiResolutionOverviewPoints is roughly how many points the overview
+	// frame aggregates the whole range into.
+	multiResolutionOverviewPoints = 200
+	// multiResolutionDetailDivisor sets the default detail span to this
+	// fraction of the range when DetailSeconds is unset.
+	multiResolutionDetailDivisor = 10
+)
+
+// multiResolutionFrames returns an overview frame with coarse aggregation over
+// the full range of query and a detail frame at the panel interval covering
+// the end of the range. The detail span never exceeds the queried range, so
+// the extra request stays bounded by it.
+func (d *Datasource) multiResolutionFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	rng := query.TimeRange.Duration()
+
+	overview := query
+	overview.Interval = max(query.Interval, (rng / multiResolutionOverviewPoints).Truncate(time.Second), time.Second)
+
+	span := time.Duration(qm.DetailSeconds) * time.Second
+	if span <= 0 {
+		span = rng / multiResolutionDetailDivisor
+	}
+	span = min(span, rng)
+	detail := query
+	detail.TimeRange.From = query.TimeRange.To.Add(-span)
+
+	overviewFrame, err := d.seriesFrame(ctx, qm, overview)
+	if err != nil {
+		return nil, fmt.Errorf("overview: %w", err)
+	}
+	detailFrame, err := d.seriesFrame(ctx, qm, detail)
+	if err != nil {
+		return nil, fmt.Errorf("detail: %w", err)
+	}
+	labelResolution(overviewFrame, "overview", overview.Interval)
+	labelResolution(detailFrame, "detail", detail.Interval)
+	return []*data.Frame{overviewFrame, detailFrame}, nil
+}
+
+// labelResolution marks the value field of frame with its resolution and
+// aggregation interval.
+func labelResolution(frame *data.Frame, resolution string, interval time.Duration) {
+	frame.Name = fmt.Sprintf("%s (%s)", frame.Name, resolution)
+	labels := data.Labels{"resolution": resolution}
+	if interval > 0 {
+		labels["interval"] = interval.String()
+	}
+	frame.Fields[1].Labels = labels
+}
@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMultiResolution(t *testing.T) {
+	var mu sync.Mutex
+	var intervals, froms []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		intervals = append(intervals, r.URL.Query().Get("aggregateInterval"))
+		froms = append(froms, r.URL.Query().Get("from"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		JSON:      []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","multi_resolution":true,"detail_seconds":3600}`),
+		Interval:  time.Minute,
+		TimeRange: backend.TimeRange{From: time.Unix(1700000000, 0), To: time.Unix(1700000000+7*86400, 0)},
+	})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("expected overview and detail frames, got %d", len(res.Frames))
+	}
+	if len(intervals) != 2 || intervals[0] != "3024s" || intervals[1] != "60s" {
+		t.Errorf("unexpected aggregate intervals %v", intervals)
+	}
+	if froms[1] != "1700601200" {
+		t.Errorf("expected detail to cover the last hour, got from=%s", froms[1])
+	}
+	overview, detail := res.Frames[0].Fields[1].Labels, res.Frames[1].Fields[1].Labels
+	if overview["resolution"] != "overview" || detail["resolution"] != "detail" {
+		t.Errorf("unexpected resolution labels %v / %v", overview, detail)
+	}
+	if overview["interval"] == detail["interval"] {
+		t.Errorf("expected distinct interval labels, got %v", overview["interval"])
+	}
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// seriesName derives the frame and field name of a series from the parts
+// addressing it, e.g. endpoint, appliance, service URI and datapoint. They
+// are joined with slashes, or made legend-friendly with SanitizeNames.
+func (d *Datasource) seriesName(parts ...string) string {
+	name := strings.Join(parts, "/")
+	if d.settings.SanitizeNames {
+		return sanitizeName(name)
+	}
+	return name
+}
+
+// sanitizeName splits name at every character that is neither a letter nor a
+// digit, capitalizes the first letter of each word and joins the words with
+// spaces, so "ep1/meter/active_power" becomes "Ep1 Meter Active Power".
+func sanitizeName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,39 @@
+package plugin
+
+import "testing"
+
+func TestSanitizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"ep1/meter/active_power":      "Ep1 Meter Active Power",
+		"ep-7/app.2//Energy.DC/total": "Ep 7 App 2 Energy DC Total",
+		"Wärme/zähler":                "Wärme Zähler",
+		"/":                           "",
+	} {
+		if got := sanitizeName(in); got != want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQuerySanitizeNames(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1}]`)
+	ds := newTestDatasource(srv.URL)
+	ds.settings.SanitizeNames = true
+
+	res := runQuery(ds, `{"endpoint_id":"ep1","appliance_id":"meter","service_uri":"energy/grid","data_point":"active_power"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Name != "Ep1 Meter Energy Grid Active Power" || frame.Fields[1].Name != frame.Name {
+		t.Errorf("expected sanitized names, got frame %q and field %q", frame.Name, frame.Fields[1].Name)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep1","appliance_id":"meter","service_uri":"energy/grid","data_point":"active_power","display_name":"grid_power"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Fields[1].Config.DisplayNameFromDS; got != "grid_power" {
+		t.Errorf("expected display name to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultListPrefetchInterval is how often lists are prefetched without a
+// configured ListPrefetchIntervalSeconds.
+const defaultListPrefetchInterval = 5 * time.Minute
+
+// listPrefetchConcurrency caps the number of endpoint descriptions a list
+// prefetch requests at the same time.
+const listPrefetchConcurrency = 8
+
+func (d *Datasource) listPrefetchInterval() time.Duration {
+	if d.settings.ListPrefetchIntervalSeconds > 0 {
+		return time.Duration(d.settings.ListPrefetchIntervalSeconds) * time.Second
+	}
+	return defaultListPrefetchInterval
+}
+
+// endpointDescriptionURL is the URL of the endpoint description appliance-list
+// reads.
+func (d *Datasource) endpointDescriptionURL(endpointID, includeConfig, draft string) string {
+	return fmt.Sprintf("%s/v1/endpoint/%s/description?includeApplianceConfiguration=%s&draft=%s", d.currentBaseURL(), endpointID, includeConfig, draft)
+}
+
+// startListPrefetch starts a goroutine that fetches the endpoint list and the
+// description of every endpoint right away and then every interval, so the
+// editor dropdowns load from cache. It is stopped by Dispose.
+func (d *Datasource) startListPrefetch(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.prefetchCancel = cancel
+	d.prefetchDone = make(chan struct{})
+	go d.runListPrefetch(ctx, interval)
+}
+
+func (d *Datasource) runListPrefetch(ctx context.Context, interval time.Duration) {
+	defer close(d.prefetchDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.prefetchLists(ctx, 2*interval); err != nil && ctx.Err() == nil {
+			log.DefaultLogger.Warn("List prefetch failed", "error", d.redact(err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prefetchLists refreshes the endpoint list and endpoint descriptions in the
+// list cache, keeping them for ttl. Descriptions are fetched at most
+// listPrefetchConcurrency at a time.
+func (d *Datasource) prefetchLists(ctx context.Context, ttl time.Duration) error {
+	if err := d.getTokenIfNeeded(ctx); err != nil {
+		return err
+	}
+	listURL := d.currentBaseURL() + "/v1/endpoint/"
+	body, errResp := d.getResourceBody(ctx, listURL)
+	if errResp != nil {
+		return fmt.Errorf("endpoint list: status %d: %s", errResp.Status, errResp.Body)
+	}
+	var endpoints []struct {
+		EndpointID string `json:"endpointId"`
+	}
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return fmt.Errorf("failed to parse endpoints: %w", err)
+	}
+	d.lists.Set(listURL, body, ttl)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(listPrefetchConcurrency)
+	for _, ep := range endpoints {
+		g.Go(func() error {
+			descURL := d.endpointDescriptionURL(ep.EndpointID, "false", "false")
+			desc, errResp := d.getResourceBody(gctx, descURL)
+			if errResp != nil {
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				log.DefaultLogger.Warn("List prefetch failed for endpoint", "endpointId", ep.EndpointID, "status", errResp.Status)
+				return nil
+			}
+			d.lists.Set(descURL, desc, ttl)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// prefetchedResourceBody is getResourceBody served from the list cache the
+// lists are prefetched into when EnableListPrefetch is set.
+func (d *Datasource) prefetchedResourceBody(ctx context.Context, url string) ([]byte, *backend.CallResourceResponse) {
+	if d.settings.EnableListPrefetch {
+		if body, ok := d.lists.Get(url); ok {
+			return body, nil
+		}
+	}
+	return d.getResourceBody(ctx, url)
+}
@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestListPrefetch(t *testing.T) {
+	var listCalls, descCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/endpoint/":
+			n := listCalls.Add(1)
+			_, _ = w.Write([]byte(`[{"endpointId":"ep` + strconv.Itoa(int(n)) + `"}]`))
+		case "/v1/endpoint/ep1/description":
+			descCalls.Add(1)
+			_, _ = w.Write([]byte(`{"processes":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.EnableListPrefetch = true
+	ds.startListPrefetch(50 * time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for listCalls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if listCalls.Load() < 3 || descCalls.Load() != 1 {
+		t.Fatalf("expected repeated refreshes, got %d list and %d description calls", listCalls.Load(), descCalls.Load())
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		ds.Dispose()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Dispose did not stop the prefetch")
+	}
+	calls := listCalls.Load()
+
+	// Served from the latest prefetched list without contacting WEMS.
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"})
+	if res.Status != http.StatusOK || string(res.Body) == `[{"endpointId":"ep1"}]` {
+		t.Errorf("unexpected response %d: %s", res.Status, res.Body)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if listCalls.Load() != calls {
+		t.Errorf("expected no requests after Dispose and from the cached list, got %d more", listCalls.Load()-calls)
+	}
+}
+
+func TestPrefetchListsFetchesDescriptionsConcurrently(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/endpoint/" {
+			var eps []string
+			for i := range 3 * listPrefetchConcurrency {
+				eps = append(eps, `{"endpointId":"ep`+strconv.Itoa(i)+`"}`)
+			}
+			_, _ = w.Write([]byte("[" + strings.Join(eps, ",") + "]"))
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"processes":[]}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	if err := ds.prefetchLists(context.Background(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p < 2 || p > listPrefetchConcurrency {
+		t.Errorf("expected between 2 and %d concurrent description requests, got %d", listPrefetchConcurrency, p)
+	}
+	if _, ok := ds.lists.Get(ds.endpointDescriptionURL("ep5", "false", "false")); !ok {
+		t.Error("expected the description in the list cache")
+	}
+}
+
+func TestSearchUsesPrefetchedDescriptions(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/v1/endpoint/":
+			_, _ = w.Write([]byte(`[{"endpointId":"ep1","friendlyName":"Plant A"}]`))
+		case "/v1/endpoint/ep1/description":
+			_, _ = w.Write([]byte(`{"processes":[{"appliances":[{"id":"app1","friendlyName":"Pump"}]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.EnableListPrefetch = true
+	if err := ds.prefetchLists(context.Background(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	prefetched := calls.Load()
+
+	for _, url := range []string{"search?type=endpoint&q=plant", "search?type=appliance&endpointId=ep1&q=pump"} {
+		res := callResource(t, ds, &backend.CallResourceRequest{Path: "search", URL: url})
+		if res.Status != http.StatusOK || !strings.Contains(string(res.Body), `"label":`) || string(res.Body) == "[]" {
+			t.Errorf("%s: unexpected response %d: %s", url, res.Status, res.Body)
+		}
+	}
+	if got := calls.Load(); got != prefetched {
+		t.Errorf("expected search to use the prefetched lists, got %d upstream calls", got-prefetched)
+	}
+}
@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// cachedDescription returns the description of the endpoint, served from the
+// list cache, which may have been prefetched, when possible. If it cannot be
+// fetched or parsed, the response to send back to the frontend is returned
+// instead.
+func (d *Datasource) cachedDescription(ctx context.Context, endpointID string) (endpointDescription, *backend.CallResourceResponse) {
+	var desc endpointDescription
+	url := d.endpointDescriptionURL(endpointID, "false", "false")
+	body, errResp := d.cachedResourceBody(ctx, url)
+	if errResp != nil {
+		return desc, errResp
+	}
+	if err := json.Unmarshal(body, &desc); err != nil {
+		return desc, &backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse endpoint description: " + err.Error()),
+		}
+	}
+	return desc, nil
+}
+
+// applianceProcess returns the name of the process the appliance belongs to,
+// taken from the cached endpoint description. Lookup failures are not fatal
+// and yield an empty name, as do appliances outside any process.
+func (d *Datasource) applianceProcess(ctx context.Context, endpointID, applianceID string) string {
+	desc, _ := d.cachedDescription(ctx, endpointID)
+	for _, proc := range desc.Processes {
+		for _, app := range proc.Appliances {
+			if app.ID == applianceID {
+				return proc.Name
+			}
+		}
+	}
+	return ""
+}
+
+// addProcessLabels sets a "process" label on the value fields of frames,
+// naming the process of the queried appliance, or of the appliance given by
+// a field's appliance_id label. Fields whose process is unknown are left
+// unchanged.
+func (d *Datasource) addProcessLabels(ctx context.Context, qm WEMSQueryModel, frames []*data.Frame) {
+	addApplianceLabels(frames, "process", qm.ApplianceID, func(applianceID string) string {
+		return d.applianceProcess(ctx, qm.EndpointID, applianceID)
+	})
+}
+
+// applianceModel returns the model name of the appliance, resolved through
+// its reference in the cached endpoint description. Lookup failures are not
+// fatal and yield an empty name.
+func (d *Datasource) applianceModel(ctx context.Context, endpointID, applianceID string) string {
+	desc, errResp := d.cachedDescription(ctx, endpointID)
+	if errResp != nil {
+		return ""
+	}
+	items, _ := desc.applianceItems()
+	for _, app := range items {
+		if app.ID == applianceID && app.ApplianceReference != 0 {
+			return d.applianceModelName(ctx, app.ApplianceReference)
+		}
+	}
+	return ""
+}
+
+// addModelLabels sets a "model" label on the value fields of frames like
+// addProcessLabels, naming the model of the appliance.
+func (d *Datasource) addModelLabels(ctx context.Context, qm WEMSQueryModel, frames []*data.Frame) {
+	addApplianceLabels(frames, "model", qm.ApplianceID, func(applianceID string) string {
+		return d.applianceModel(ctx, qm.EndpointID, applianceID)
+	})
+}
+
+// addApplianceLabels sets the label key on the value fields of frames to the
+// name lookup returns for the field's appliance: the one given by its
+// appliance_id label, else applianceID. Each appliance is looked up once;
+// fields for which lookup returns an empty name are left unchanged.
+func addApplianceLabels(frames []*data.Frame, key, applianceID string, lookup func(applianceID string) string) {
+	names := make(map[string]string)
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Type().Time() {
+				continue
+			}
+			id := applianceID
+			if v, ok := field.Labels["appliance_id"]; ok {
+				id = v
+			}
+			name, ok := names[id]
+			if !ok {
+				name = lookup(id)
+				names[id] = name
+			}
+			if name == "" {
+				continue
+			}
+			if field.Labels == nil {
+				field.Labels = data.Labels{}
+			}
+			field.Labels[key] = name
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessLabels(t *testing.T) {
+	var descriptions atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/description") {
+			descriptions.Add(1)
+			_, _ = w.Write([]byte(`{"processes":[{"id":"p1","name":"Heating","appliances":[{"id":"app"}]},{"id":"p2","name":"Cooling","appliances":[{"id":"app2"}]}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if _, ok := res.Frames[0].Fields[1].Labels["process"]; ok || descriptions.Load() != 0 {
+		t.Fatal("expected no process lookup unless enabled")
+	}
+
+	ds.settings.ProcessLabels = true
+	for i := 0; i < 2; i++ {
+		res = runQuery(ds, model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got := res.Frames[0].Fields[1].Labels["process"]; got != "Heating" {
+			t.Errorf("expected process label Heating, got %q", got)
+		}
+	}
+	if got := descriptions.Load(); got != 1 {
+		t.Errorf("expected the description to be cached, got %d requests", got)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_ids":["app","app2"],"service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	for i, want := range []string{"Heating", "Cooling"} {
+		if got := res.Frames[i].Fields[1].Labels["process"]; got != want {
+			t.Errorf("frame %d: expected process label %s, got %q", i, want, got)
+		}
+	}
+}
+
+func TestModelLabels(t *testing.T) {
+	var models atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/description"):
+			_, _ = w.Write([]byte(`{"processes":[{"id":"p1","name":"Heating","appliances":[{"id":"app","applianceReference":42},{"id":"app2"}]}]}`))
+		case r.URL.Path == "/v1/component/appliance/42":
+			models.Add(1)
+			_, _ = w.Write([]byte(`{"friendlyName":"Heat Pump 3000"}`))
+		default:
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if _, ok := res.Frames[0].Fields[1].Labels["model"]; ok || models.Load() != 0 {
+		t.Fatal("expected no model lookup unless enabled")
+	}
+
+	ds.settings.ModelLabels = true
+	for i := 0; i < 2; i++ {
+		res = runQuery(ds, model)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got := res.Frames[0].Fields[1].Labels["model"]; got != "Heat Pump 3000" {
+			t.Errorf("expected model label Heat Pump 3000, got %q", got)
+		}
+	}
+	if got := models.Load(); got != 1 {
+		t.Errorf("expected the model name to be cached, got %d requests", got)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app2","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if _, ok := res.Frames[0].Fields[1].Labels["model"]; ok {
+		t.Error("expected no model label for an appliance without a reference")
+	}
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/errgroup"
+)
+
+// ratioFrame fetches the Numerator and Denominator series of qm concurrently
+// and returns their ratio per timestamp as a single frame. The ratio is null
+// where either series has no value or the denominator is zero.
+func (d *Datasource) ratioFrame(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) (*data.Frame, error) {
+	targets := []QueryTarget{*qm.Numerator, *qm.Denominator}
+	values := make([]map[int64]float64, len(targets))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, target := range targets {
+		g.Go(func() error {
+			tqm := qm
+			tqm.Numerator, tqm.Denominator = nil, nil
+			tqm.ServiceURI = target.ServiceURI
+			tqm.DataPoint = target.DataPoint
+			points, _, err := d.seriesPoints(gctx, tqm, query)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", target.ServiceURI, target.DataPoint, err)
+			}
+			values[i], err = pointValues(points, d.convertOptions())
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", target.ServiceURI, target.DataPoint, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	numerator, denominator := values[0], values[1]
+	times := slices.Sorted(maps.Keys(numerator))
+	for t := range denominator {
+		if _, ok := numerator[t]; !ok {
+			times = append(times, t)
+		}
+	}
+	slices.Sort(times)
+	frameTimes := make([]time.Time, len(times))
+	ratios := make([]*float64, len(times))
+	for i, t := range times {
+		frameTimes[i] = time.Unix(t, 0).UTC()
+		n, nok := numerator[t]
+		dv, dok := denominator[t]
+		if nok && dok && dv != 0 {
+			r := n / dv
+			ratios[i] = &r
+		}
+	}
+	ratioField := data.NewField("ratio", data.Labels{
+		"numerator":   qm.Numerator.ServiceURI + "/" + qm.Numerator.DataPoint,
+		"denominator": qm.Denominator.ServiceURI + "/" + qm.Denominator.DataPoint,
+	}, ratios)
+	if qm.DisplayName != "" {
+		ratioField.Config = &data.FieldConfig{DisplayNameFromDS: qm.DisplayName}
+	}
+	return data.NewFrame(d.seriesName(qm.EndpointID, qm.ApplianceID, "ratio"),
+		data.NewField("time", nil, frameTimes),
+		ratioField,
+	), nil
+}
+
+// pointValues returns the numeric values of points keyed by time, leaving
+// out points without a value or whose value cannot be converted, as well as
+// NaN and infinite values unless OnNonFinite fails the query. Of several
+// points sharing a time the last one wins.
+func pointValues(points []TimeSeriesDataPoint, opts convertOptions) (map[int64]float64, error) {
+	values := make(map[int64]float64, len(points))
+	for _, p := range points {
+		if p.Value == nil {
+			continue
+		}
+		f, ok := convertValue(p.Value, opts)
+		if err := opts.checkFinite(f, time.Unix(p.Time, 0).UTC()); err != nil {
+			return nil, err
+		}
+		if ok && !isNonFinite(f) {
+			values[p.Time] = f
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryRatio(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/meter/active"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":80},{"time":1700000060,"value":90},{"time":1700000120,"value":50},{"time":1700000240,"value":null}]`))
+		case strings.HasSuffix(r.URL.Path, "/meter/apparent"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":100},{"time":1700000120,"value":0},{"time":1700000180,"value":70},{"time":1700000240,"value":10}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app",
+		"numerator":{"service_uri":"meter","data_point":"active"},
+		"denominator":{"service_uri":"meter","data_point":"apparent"}}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("expected a single ratio frame, got %d", len(res.Frames))
+	}
+	frame := res.Frames[0]
+	field := frame.Fields[1]
+	if field.Labels["numerator"] != "meter/active" || field.Labels["denominator"] != "meter/apparent" {
+		t.Errorf("unexpected labels %v", field.Labels)
+	}
+	want := []struct {
+		time  int64
+		ratio *float64
+	}{
+		{1700000000, ptr(0.8)},
+		{1700000060, nil}, // no denominator
+		{1700000120, nil}, // zero denominator
+		{1700000180, nil}, // no numerator
+		{1700000240, nil}, // null numerator
+	}
+	if frame.Rows() != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), frame.Rows())
+	}
+	for i, w := range want {
+		if got := frame.Fields[0].At(i).(time.Time); got.Unix() != w.time {
+			t.Errorf("row %d: expected time %d, got %d", i, w.time, got.Unix())
+		}
+		got := field.At(i).(*float64)
+		if (got == nil) != (w.ratio == nil) || (got != nil && *got != *w.ratio) {
+			t.Errorf("row %d: expected %v, got %v", i, w.ratio, got)
+		}
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","numerator":{"service_uri":"meter","data_point":"active"}}`)
+	if res.Error == nil {
+		t.Error("expected a ratio without denominator to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import "fmt"
+
+// Bucket reducers selecting the value kept for points that fall into the same
+// client-side bucket, see WEMSQueryModel.BucketReducer.
+const (
+	// BucketReducerFirst keeps the earliest point.
+	BucketReducerFirst = "first"
+	// BucketReducerLast keeps the latest point.
+	BucketReducerLast = "last"
+	// BucketReducerMax keeps the point with the largest value.
+	BucketReducerMax = "max"
+	// BucketReducerMin keeps the point with the smallest value.
+	BucketReducerMin = "min"
+	// BucketReducerMean replaces the points by the mean of their values.
+	BucketReducerMean = "mean"
+	// BucketReducerSum replaces the points by the sum of their values.
+	BucketReducerSum = "sum"
+)
+
+// validateBucketReducer returns an error if reducer is not a known bucket
+// reducer. An empty reducer selects the default of each bucketing step.
+func validateBucketReducer(reducer string) error {
+	switch reducer {
+	case "", BucketReducerFirst, BucketReducerLast, BucketReducerMax, BucketReducerMin, BucketReducerMean, BucketReducerSum:
+		return nil
+	default:
+		return fmt.Errorf("unsupported bucket_reducer %q", reducer)
+	}
+}
+
+// reduceBucket returns the point representing bucket, which must not be
+// empty and be sorted by time. Values are compared after numeric conversion
+// with opts; nulls and values that cannot be converted are ignored by max,
+// min, mean and sum, which fall back to the last point if no value is left. The
+// time of the returned point is left to the caller.
+func reduceBucket(bucket []TimeSeriesDataPoint, reducer string, opts convertOptions) TimeSeriesDataPoint {
+	switch reducer {
+	case BucketReducerFirst:
+		return bucket[0]
+	case BucketReducerMax, BucketReducerMin, BucketReducerMean, BucketReducerSum:
+	default:
+		return bucket[len(bucket)-1]
+	}
+
+	best, n := -1, 0
+	var bestValue, sum float64
+	for i, p := range bucket {
+		if p.Value == nil {
+			continue
+		}
+		v, ok := convertValue(p.Value, opts)
+		if !ok {
+			continue
+		}
+		n++
+		sum += v
+		if best < 0 || (reducer == BucketReducerMax && v > bestValue) || (reducer == BucketReducerMin && v < bestValue) {
+			best, bestValue = i, v
+		}
+	}
+	switch {
+	case best < 0:
+		return bucket[len(bucket)-1]
+	case reducer == BucketReducerMean:
+		return TimeSeriesDataPoint{Time: bucket[len(bucket)-1].Time, Value: sum / float64(n)}
+	case reducer == BucketReducerSum:
+		return TimeSeriesDataPoint{Time: bucket[len(bucket)-1].Time, Value: sum}
+	default:
+		return bucket[best]
+	}
+}
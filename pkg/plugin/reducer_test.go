@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReduceBucket(t *testing.T) {
+	bucket := []TimeSeriesDataPoint{
+		{Time: 10, Value: 3.0},
+		{Time: 11, Value: nil},
+		{Time: 12, Value: "7"},
+		{Time: 13, Value: 2.0},
+	}
+	for _, tc := range []struct {
+		reducer string
+		want    interface{}
+	}{
+		{"", 2.0},
+		{BucketReducerFirst, 3.0},
+		{BucketReducerLast, 2.0},
+		{BucketReducerMax, "7"},
+		{BucketReducerMin, 2.0},
+		{BucketReducerMean, 4.0},
+		{BucketReducerSum, 12.0},
+	} {
+		if got := reduceBucket(bucket, tc.reducer, convertOptions{}); got.Value != tc.want {
+			t.Errorf("%q: expected %v, got %v", tc.reducer, tc.want, got.Value)
+		}
+	}
+
+	nulls := []TimeSeriesDataPoint{{Time: 1, Value: nil}, {Time: 2, Value: "n/a"}}
+	if got := reduceBucket(nulls, BucketReducerMax, convertOptions{}); got.Time != 2 {
+		t.Errorf("expected fallback to the last point, got %+v", got)
+	}
+}
+
+func TestResampleBucketReducer(t *testing.T) {
+	points := []TimeSeriesDataPoint{
+		{Time: 100, Value: 4.0},
+		{Time: 105, Value: 1.0},
+		{Time: 110, Value: 5.0},
+		{Time: 115, Value: 9.0},
+	}
+	for _, tc := range []struct {
+		reducer string
+		want    []float64
+	}{
+		{BucketReducerFirst, []float64{4, 5}},
+		{BucketReducerLast, []float64{1, 9}},
+		{BucketReducerMax, []float64{4, 9}},
+		{BucketReducerMin, []float64{1, 5}},
+		{BucketReducerMean, []float64{2.5, 7}},
+	} {
+		got := resamplePoints(points, time.Unix(100, 0), time.Unix(119, 0), 10*time.Second, FillModeNone, tc.reducer, convertOptions{})
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %d slots, got %+v", tc.reducer, len(tc.want), got)
+		}
+		for i, want := range tc.want {
+			if got[i].Time != int64(100+10*i) || got[i].Value != want {
+				t.Errorf("%s: slot %d: expected %v, got %+v", tc.reducer, i, want, got[i])
+			}
+		}
+	}
+}
+
+func TestDedupeBucketReducer(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700000000,"value":5},{"time":1700000000,"value":3},{"time":1700000060,"value":4}]`)
+	ds := newTestDatasource(srv.URL)
+
+	for reducer, want := range map[string]float64{
+		BucketReducerFirst: 1,
+		BucketReducerLast:  3,
+		BucketReducerMax:   5,
+		BucketReducerMin:   1,
+		BucketReducerMean:  3,
+	} {
+		res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","dedupe_timestamps":true,"bucket_reducer":"`+reducer+`"}`)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		field := res.Frames[0].Fields[1]
+		if field.Len() != 2 {
+			t.Fatalf("%s: expected 2 points, got %d", reducer, field.Len())
+		}
+		if got := field.At(0).(float64); got != want {
+			t.Errorf("%s: expected %v, got %v", reducer, want, got)
+		}
+	}
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","bucket_reducer":"median"}`)
+	if res.Error == nil {
+		t.Fatal("expected error for unsupported reducer")
+	}
+}
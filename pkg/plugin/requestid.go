@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// requestIDHeader carries the ID generated for every request sent to WEMS, so
+// plugin logs can be correlated with WEMS server logs.
+const requestIDHeader = "X-Request-ID"
+
+// send tags req with a new request ID, sends it with client and logs the
+// outcome. Transport errors are annotated with the request ID.
+func (d *Datasource) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	id := uuid.NewString()
+	req.Header.Set(requestIDHeader, id)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		log.DefaultLogger.Debug("WEMS request failed", "requestId", id, "method", req.Method, "path", req.URL.Path, "error", d.redact(err.Error()))
+		return nil, fmt.Errorf("request %s: %w", id, err)
+	}
+	log.DefaultLogger.Debug("WEMS request", "requestId", id, "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "duration", time.Since(start))
+	if d.settings.VerboseLogging {
+		d.logVerbose(id, req, resp)
+	}
+	return resp, nil
+}
+
+// requestIDOf returns the request ID sent with the request that produced resp.
+func requestIDOf(resp *http.Response) string {
+	if resp.Request == nil {
+		return ""
+	}
+	return resp.Request.Header.Get(requestIDHeader)
+}
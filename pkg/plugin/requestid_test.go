@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestRequestIDHeader(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get(requestIDHeader))
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/token":
+			_, _ = w.Write([]byte("token"))
+		case strings.Contains(r.URL.Path, "/series/"):
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	ds := &Datasource{baseURL: srv.URL}
+	if err := ds.getTokenIfNeeded(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"})
+
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 upstream requests, got %d", len(ids))
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id == "" {
+			t.Fatal("request sent without request ID")
+		}
+		if seen[id] {
+			t.Fatalf("request ID %s reused", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRequestIDInErrors(t *testing.T) {
+	var id string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = r.Header.Get(requestIDHeader)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error == nil || id == "" || !strings.Contains(res.Error.Error(), id) {
+		t.Fatalf("expected error to mention request ID %q, got %v", id, res.Error)
+	}
+
+	resp := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"})
+	if got := resp.Headers[requestIDHeader]; len(got) != 1 || got[0] != id {
+		t.Errorf("expected resource error to carry request ID %q, got %v", id, got)
+	}
+}
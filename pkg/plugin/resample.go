@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Fill modes for grid slots without a point, see WEMSQueryModel.FillMode.
+const (
+	// FillModeNone leaves empty slots out of the result.
+	FillModeNone = "none"
+	// FillModeForward repeats the last known value.
+	FillModeForward = "forward"
+	// FillModeZero fills empty slots with 0.
+	FillModeZero = "zero"
+)
+
+// parseResampleStep validates the resampling options of qm and returns the
+// step. A zero step means resampling is disabled.
+func parseResampleStep(qm WEMSQueryModel) (time.Duration, error) {
+	switch qm.FillMode {
+	case "", FillModeNone, FillModeForward, FillModeZero:
+	default:
+		return 0, fmt.Errorf("unsupported fill_mode %q", qm.FillMode)
+	}
+	if qm.ResampleStep == "" {
+		return 0, nil
+	}
+	step, err := time.ParseDuration(qm.ResampleStep)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resample_step %q: %w", qm.ResampleStep, err)
+	}
+	if step < time.Second {
+		return 0, fmt.Errorf("invalid resample_step %q: must be at least 1s", qm.ResampleStep)
+	}
+	return step, nil
+}
+
+// resampleStart returns the first grid time at or after from.
+func resampleStart(from time.Time, stepSec int64) int64 {
+	start := from.Unix() - from.Unix()%stepSec
+	if start < from.Unix() {
+		start += stepSec
+	}
+	return start
+}
+
+// resampleSlots returns the number of grid slots resamplePoints produces for
+// the range from to to, at most.
+func resampleSlots(from, to time.Time, step time.Duration) int64 {
+	stepSec := int64(step / time.Second)
+	start := resampleStart(from, stepSec)
+	if to.Unix() < start {
+		return 0
+	}
+	return (to.Unix()-start)/stepSec + 1
+}
+
+// checkResampleSlots returns an error if resampling with step over the range
+// from to to yields more than max slots, which filling would all allocate.
+func checkResampleSlots(from, to time.Time, step time.Duration, max int) error {
+	if slots := resampleSlots(from, to, step); slots > int64(max) {
+		return fmt.Errorf("resample_step %s yields %d points over the time range, more than the maximum of %d: use a larger step", step, slots, max)
+	}
+	return nil
+}
+
+// resamplePoints maps points onto a grid of the given step covering from to
+// to, with grid times aligned to multiples of step. The points falling into a
+// slot are combined by reduceBucket with reducer, keeping the last one by
+// default; empty slots are handled according to fill; filled slots are
+// marked as interpolated. Points before the first slot only seed forward
+// filling. The input is not modified.
+func resamplePoints(points []TimeSeriesDataPoint, from, to time.Time, step time.Duration, fill, reducer string, opts convertOptions) []TimeSeriesDataPoint {
+	sorted := slices.Clone(points)
+	slices.SortStableFunc(sorted, comparePointTimes)
+
+	stepSec := int64(step / time.Second)
+	start := resampleStart(from, stepSec)
+	end := to.Unix()
+
+	result := make([]TimeSeriesDataPoint, 0, resampleSlots(from, to, step))
+	var last interface{}
+	var lastInterpolated *bool
+	var bucket []TimeSeriesDataPoint
+	filled := true
+	i := 0
+	for slot := start; slot <= end; slot += stepSec {
+		bucket = bucket[:0]
+		for i < len(sorted) && sorted[i].Time < slot+stepSec {
+			if sorted[i].Time >= slot {
+				bucket = append(bucket, sorted[i])
+			} else {
+				last, lastInterpolated = sorted[i].Value, sorted[i].Interpolated
+			}
+			i++
+		}
+		switch {
+		case len(bucket) > 0:
+			p := reduceBucket(bucket, reducer, opts)
+			last, lastInterpolated = p.Value, p.Interpolated
+			result = append(result, TimeSeriesDataPoint{Time: slot, Value: last, Interpolated: lastInterpolated})
+		case fill == FillModeForward && last != nil:
+			result = append(result, TimeSeriesDataPoint{Time: slot, Value: last, Interpolated: &filled})
+		case fill == FillModeZero:
+			result = append(result, TimeSeriesDataPoint{Time: slot, Value: float64(0), Interpolated: &filled})
+		}
+	}
+	return result
+}
+
+// interpolatedField returns an "interpolated" field flagging the points of a
+// series that were filled in rather than measured, or nil if no point carries
+// that information.
+func interpolatedField(points []TimeSeriesDataPoint) *data.Field {
+	if !slices.ContainsFunc(points, func(p TimeSeriesDataPoint) bool { return p.Interpolated != nil }) {
+		return nil
+	}
+	flags := make([]bool, len(points))
+	for i, p := range points {
+		flags[i] = p.Interpolated != nil && *p.Interpolated
+	}
+	return data.NewField("interpolated", nil, flags)
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResamplePoints(t *testing.T) {
+	points := []TimeSeriesDataPoint{
+		{Time: 130, Value: 3.0},
+		{Time: 50, Value: 0.5},
+		{Time: 100, Value: 1.0},
+		{Time: 110, Value: 2.0},
+	}
+	from, to := time.Unix(100, 0), time.Unix(160, 0)
+
+	for _, tc := range []struct {
+		fill  string
+		times []int64
+		want  []float64
+	}{
+		{FillModeNone, []int64{100, 120}, []float64{2, 3}},
+		{"", []int64{100, 120}, []float64{2, 3}},
+		{FillModeForward, []int64{100, 120, 140, 160}, []float64{2, 3, 3, 3}},
+		{FillModeZero, []int64{100, 120, 140, 160}, []float64{2, 3, 0, 0}},
+	} {
+		got := resamplePoints(points, from, to, 20*time.Second, tc.fill, "", convertOptions{})
+		if len(got) != len(tc.times) {
+			t.Fatalf("%q: expected %d points, got %v", tc.fill, len(tc.times), got)
+		}
+		for i, p := range got {
+			if p.Time != tc.times[i] || p.Value.(float64) != tc.want[i] {
+				t.Errorf("%q: point %d: expected %d=%v, got %d=%v", tc.fill, i, tc.times[i], tc.want[i], p.Time, p.Value)
+			}
+		}
+	}
+	if points[0].Time != 130 {
+		t.Error("input points were reordered")
+	}
+}
+
+func TestResampleForwardFillsFromEarlierPoint(t *testing.T) {
+	points := []TimeSeriesDataPoint{{Time: 90, Value: 7.0}}
+	got := resamplePoints(points, time.Unix(95, 0), time.Unix(120, 0), 10*time.Second, FillModeForward, "", convertOptions{})
+	if len(got) != 3 || got[0].Time != 100 || got[0].Value.(float64) != 7 {
+		t.Fatalf("expected 3 forward-filled points starting at 100, got %v", got)
+	}
+}
+
+func TestQueryResample(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1},{"time":1700001800,"value":2}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","resample_step":"10m","fill_mode":"forward"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	// 1700000000 is not a multiple of 10m, so the grid starts at 1700000400.
+	field := res.Frames[0].Fields[1]
+	if field.Len() != 6 {
+		t.Fatalf("expected 6 grid points, got %d", field.Len())
+	}
+	if got := field.At(0).(float64); got != 1 {
+		t.Errorf("expected first slot forward-filled with 1, got %v", got)
+	}
+	if got := field.At(5).(float64); got != 2 {
+		t.Errorf("expected last slot forward-filled with 2, got %v", got)
+	}
+
+	for _, model := range []string{
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","resample_step":"soon"}`,
+		`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","resample_step":"1m","fill_mode":"linear"}`,
+	} {
+		if res := runQuery(ds, model); res.Error == nil {
+			t.Errorf("%s: expected error", model)
+		}
+	}
+}
+
+func TestQueryInterpolatedField(t *testing.T) {
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"%s}`
+
+	// Points marked by WEMS are carried over as is.
+	ds := newTestDatasource(seriesServer(t, `[{"time":1700000000,"value":1,"interpolated":false},{"time":1700000060,"value":1,"interpolated":true}]`).URL)
+	res := runQuery(ds, fmt.Sprintf(model, ""))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	field, _ := res.Frames[0].FieldByName("interpolated")
+	if field == nil || field.At(0).(bool) || !field.At(1).(bool) {
+		t.Fatalf("expected interpolated flags false,true, got %v", field)
+	}
+
+	// Slots filled by resampling are flagged; unmarked series get no field.
+	ds = newTestDatasource(seriesServer(t, `[{"time":1700000400,"value":5},{"time":1700001600,"value":6}]`).URL)
+	if res := runQuery(ds, fmt.Sprintf(model, "")); len(res.Frames[0].Fields) != 2 {
+		t.Fatalf("expected no interpolated field for unmarked series, got %d fields", len(res.Frames[0].Fields))
+	}
+	res = runQuery(ds, fmt.Sprintf(model, `,"resample_step":"10m","fill_mode":"zero"`))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	values := res.Frames[0].Fields[1]
+	field, _ = res.Frames[0].FieldByName("interpolated")
+	if field == nil || field.Len() != values.Len() {
+		t.Fatalf("expected interpolated field aligned with %d values, got %v", values.Len(), field)
+	}
+	for i := 0; i < values.Len(); i++ {
+		measured := values.At(i).(float64) != 0
+		if field.At(i).(bool) == measured {
+			t.Errorf("point %d (value %v): unexpected interpolated flag %v", i, values.At(i), field.At(i))
+		}
+	}
+}
+
+func TestQueryResampleRejectsTooManySlots(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxPoints = 100
+
+	// One hour at 1s is 3601 slots.
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","resample_step":"1s","fill_mode":"zero"}`)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "3601 points") {
+		t.Fatalf("expected an error for too many slots, got %v", res.Error)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("expected no upstream request, got %d", got)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","resample_step":"1m","fill_mode":"zero"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if n := res.Frames[0].Rows(); n != 60 {
+		t.Errorf("expected 60 slots, got %d", n)
+	}
+}
@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// listCacheTTL is how long the search and paged endpoint-list resources reuse
+// a fetched endpoint list or endpoint description.
+const listCacheTTL = 30 * time.Second
+
+// resourceMethods lists the HTTP methods each resource accepts. All resources
+// are read-only; diagnose takes its query as a POST body.
+var resourceMethods = map[string][]string{
+	"endpoint-list":      {http.MethodGet},
+	"endpoint-status":    {http.MethodGet},
+	"appliance-list":     {http.MethodGet},
+	"appliance-config":   {http.MethodGet},
+	"service-list":       {http.MethodGet},
+	"datapoint-list":     {http.MethodGet},
+	"datapoint-unit":     {http.MethodGet},
+	"export-csv":         {http.MethodGet},
+	"export-arrow":       {http.MethodGet},
+	"search":             {http.MethodGet},
+	"datapoint-range":    {http.MethodGet},
+	"config":             {http.MethodGet},
+	"resolve-appliances": {http.MethodGet},
+	"diagnose":           {http.MethodPost},
+}
+
+// getResourceBody fetches url from WEMS with the current token. If the request
+// fails or WEMS answers with a non-200 status, the response to send back to the
+// frontend is returned instead of a body. With ConditionalRequests, bodies
+// sent with an ETag are revalidated rather than fetched again.
+func (d *Datasource) getResourceBody(ctx context.Context, url string) ([]byte, *backend.CallResourceResponse) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, &backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to create request: " + err.Error()),
+		}
+	}
+	request.Header.Set("Authorization", "Bearer "+d.bearer(ctx))
+	request.Header.Set("Accept", "application/json")
+	d.setIfNoneMatch(request, url)
+
+	client := d.httpClient(20 * time.Second)
+	resp, err := d.send(client, request)
+	if err != nil {
+		return nil, &backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Request failed: " + err.Error()),
+		}
+	}
+	defer resp.Body.Close()
+	if body, ok := d.notModifiedBody(resp, url); ok {
+		return body, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to read response: " + err.Error()),
+		}
+	}
+	if resp.StatusCode != 200 {
+		return nil, &backend.CallResourceResponse{
+			Status:  resp.StatusCode,
+			Headers: map[string][]string{requestIDHeader: {requestIDOf(resp)}},
+			Body:    body,
+		}
+	}
+	d.rememberETag(resp, url, body)
+	return body, nil
+}
+
+// cachedResourceBody is getResourceBody backed by the list cache.
+func (d *Datasource) cachedResourceBody(ctx context.Context, url string) ([]byte, *backend.CallResourceResponse) {
+	if body, ok := d.lists.Get(url); ok {
+		return body, nil
+	}
+	body, errResp := d.getResourceBody(ctx, url)
+	if errResp != nil {
+		return nil, errResp
+	}
+	d.lists.Set(url, body, listCacheTTL)
+	return body, nil
+}
+
+// sendJSON marshals v and sends it as a 200 resource response.
+func sendJSON(sender backend.CallResourceResponseSender, v interface{}) error {
+	respBytes, err := json.Marshal(v)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to encode response: " + err.Error()),
+		})
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    respBytes,
+	})
+}
+
+// editorConfig is the response of the config resource: the settings the
+// query editor shows as context and defaults. It must never carry secrets.
+type editorConfig struct {
+	BaseURL                   string   `json:"baseUrl"`
+	BaseURLs                  []string `json:"baseUrls,omitempty"`
+	DefaultAggregateFunction  string   `json:"defaultAggregateFunction"`
+	MinInterval               string   `json:"minInterval"`
+	Timezone                  string   `json:"timezone"`
+	FrameFormat               string   `json:"frameFormat"`
+	QueryTimeoutSeconds       int      `json:"queryTimeoutSeconds"`
+	HealthCheckTimeoutSeconds int      `json:"healthCheckTimeoutSeconds"`
+}
+
+// configResource serves the config resource with the non-secret settings
+// of the datasource.
+func (d *Datasource) configResource(sender backend.CallResourceResponseSender) error {
+	return sendJSON(sender, editorConfig{
+		BaseURL:                   d.currentBaseURL(),
+		BaseURLs:                  d.baseURLs,
+		DefaultAggregateFunction:  d.settings.DefaultAggregateFunction,
+		MinInterval:               d.settings.MinInterval,
+		Timezone:                  d.settings.Timezone,
+		FrameFormat:               d.settings.FrameFormat,
+		QueryTimeoutSeconds:       int(defaultQueryTimeout / time.Second),
+		HealthCheckTimeoutSeconds: int(d.healthCheckTimeout() / time.Second),
+	})
+}
+
+// serviceLabel returns the human-readable name WEMS reports for the service
+// at uri, or uri itself if the service values carry none.
+func serviceLabel(uri string, values json.RawMessage) string {
+	var service struct {
+		FriendlyName string `json:"friendlyName"`
+	}
+	if json.Unmarshal(values, &service) == nil && service.FriendlyName != "" {
+		return service.FriendlyName
+	}
+	return uri
+}
+
+// endpointStatus is an entry of the endpoint-status resource.
+type endpointStatus struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Online   bool   `json:"online"`
+	LastSeen *int64 `json:"lastSeen"`
+}
+
+// endpointStatusList serves the endpoint-status resource, listing every
+// endpoint together with its connectivity as reported by WEMS.
+func (d *Datasource) endpointStatusList(ctx context.Context, sender backend.CallResourceResponseSender) error {
+	body, errResp := d.getResourceBody(ctx, d.currentBaseURL()+"/v1/endpoint/")
+	if errResp != nil {
+		return sender.Send(errResp)
+	}
+	var endpoints []struct {
+		EndpointID   string `json:"endpointId"`
+		FriendlyName string `json:"friendlyName"`
+		Online       bool   `json:"online"`
+		LastSeen     *int64 `json:"lastSeen"`
+	}
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse endpoints: " + err.Error()),
+		})
+	}
+	result := make([]endpointStatus, 0, len(endpoints))
+	for _, ep := range endpoints {
+		label := ep.FriendlyName
+		if label == "" {
+			label = ep.EndpointID
+		}
+		result = append(result, endpointStatus{
+			ID:       ep.EndpointID,
+			Label:    label,
+			Online:   ep.Online,
+			LastSeen: ep.LastSeen,
+		})
+	}
+	return sendJSON(sender, result)
+}
+
+// defaultEndpointPageSize and maxEndpointPageSize bound the pageSize
+// parameter of the paged endpoint-list resource.
+const (
+	defaultEndpointPageSize = 100
+	maxEndpointPageSize     = 1000
+)
+
+// endpointPageResponse is the response of the paged endpoint-list resource.
+type endpointPageResponse struct {
+	Items    []json.RawMessage `json:"items"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+	Total    int               `json:"total"`
+}
+
+// endpointPage serves endpoint-list with page/pageSize parameters, slicing
+// the cached endpoint list. Pages start at 1; a page past the end is empty.
+func (d *Datasource) endpointPage(ctx context.Context, query url.Values, sender backend.CallResourceResponseSender) error {
+	page, pageSize := 1, defaultEndpointPageSize
+	var err error
+	if v := query.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   []byte("Invalid page parameter"),
+			})
+		}
+	}
+	if v := query.Get("pageSize"); v != "" {
+		if pageSize, err = strconv.Atoi(v); err != nil || pageSize < 1 || pageSize > maxEndpointPageSize {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   []byte("Invalid pageSize parameter"),
+			})
+		}
+	}
+
+	body, errResp := d.cachedResourceBody(ctx, d.currentBaseURL()+"/v1/endpoint/")
+	if errResp != nil {
+		return sender.Send(errResp)
+	}
+	var endpoints []json.RawMessage
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse endpoints: " + err.Error()),
+		})
+	}
+	start := min((page-1)*pageSize, len(endpoints))
+	end := min(start+pageSize, len(endpoints))
+	return sendJSON(sender, endpointPageResponse{
+		Items:    append([]json.RawMessage{}, endpoints[start:end]...),
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(endpoints),
+	})
+}
+
+// resolveAppliances serves the resolve-appliances resource, mapping each of
+// the comma-separated applianceIds of an endpoint to its friendly name as
+// listed in the cached endpoint description. IDs that are unknown or have no
+// friendly name map to themselves.
+func (d *Datasource) resolveAppliances(ctx context.Context, params url.Values, sender backend.CallResourceResponseSender) error {
+	endpointID := params.Get("endpointId")
+	if endpointID == "" || params.Get("applianceIds") == "" {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Missing endpointId or applianceIds parameter"),
+		})
+	}
+	desc, errResp := d.cachedDescription(ctx, endpointID)
+	if errResp != nil {
+		return sender.Send(errResp)
+	}
+	items, err := desc.applianceItems()
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse endpoint description: " + err.Error()),
+		})
+	}
+	names := make(map[string]string, len(items))
+	for _, item := range items {
+		if item.FriendlyName != "" {
+			names[item.ID] = item.FriendlyName
+		}
+	}
+	result := make(map[string]string)
+	for _, id := range strings.Split(params.Get("applianceIds"), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		if name, ok := names[id]; ok {
+			result[id] = name
+		} else {
+			result[id] = id
+		}
+	}
+	return sendJSON(sender, result)
+}
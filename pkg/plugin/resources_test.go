@@ -0,0 +1,264 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestEndpointStatus(t *testing.T) {
+	srv := seriesServer(t, `[
+		{"endpointId":"ep1","friendlyName":"Plant A","online":true,"lastSeen":1700000000},
+		{"endpointId":"ep2","online":false}
+	]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-status", URL: "endpoint-status"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var got []endpointStatus
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(got))
+	}
+	if got[0].ID != "ep1" || got[0].Label != "Plant A" || !got[0].Online || got[0].LastSeen == nil || *got[0].LastSeen != 1700000000 {
+		t.Errorf("unexpected first endpoint %+v", got[0])
+	}
+	if got[1].ID != "ep2" || got[1].Label != "ep2" || got[1].Online || got[1].LastSeen != nil {
+		t.Errorf("unexpected second endpoint %+v", got[1])
+	}
+}
+
+func TestServiceListLabels(t *testing.T) {
+	srv := seriesServer(t, `{
+		"/meter/1": {"friendlyName":"Grid meter","dataPoints":{}},
+		"/battery/1": {"dataPoints":{}}
+	}`)
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "service-list", URL: "service-list?endpointId=ep&applianceId=app"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	var got []map[string]string
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{}
+	for _, s := range got {
+		labels[s["uri"]] = s["label"]
+	}
+	if labels["/meter/1"] != "Grid meter" || labels["/battery/1"] != "/battery/1" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+}
+
+func TestApplianceListDraftPassthrough(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		_, _ = w.Write([]byte(`{"processes":[]}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-list", URL: "appliance-list?endpointId=ep"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if gotQuery.Get("draft") != "false" || gotQuery.Get("includeApplianceConfiguration") != "false" {
+		t.Errorf("unexpected default params %v", gotQuery)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-list", URL: "appliance-list?endpointId=ep&draft=true&includeApplianceConfiguration=true"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if gotQuery.Get("draft") != "true" || gotQuery.Get("includeApplianceConfiguration") != "true" {
+		t.Errorf("params not passed through: %v", gotQuery)
+	}
+
+	res = callResource(t, ds, &backend.CallResourceRequest{Path: "appliance-list", URL: "appliance-list?endpointId=ep&draft=maybe"})
+	if res.Status != http.StatusBadRequest {
+		t.Errorf("expected bad request for invalid draft, got %d", res.Status)
+	}
+}
+
+func TestResourceMethodNotAllowed(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		res := callResource(t, ds, &backend.CallResourceRequest{Method: method, Path: "endpoint-list", URL: "endpoint-list"})
+		if res.Status != http.StatusMethodNotAllowed {
+			t.Errorf("%s: expected 405, got %d", method, res.Status)
+		}
+		if got := res.Headers["Allow"]; len(got) != 1 || got[0] != http.MethodGet {
+			t.Errorf("%s: expected Allow: GET, got %v", method, got)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("expected rejected requests not to reach WEMS, got %d calls", calls)
+	}
+	if res := callResource(t, ds, &backend.CallResourceRequest{Method: http.MethodGet, Path: "endpoint-list", URL: "endpoint-list"}); res.Status != http.StatusOK {
+		t.Errorf("expected GET to succeed, got %d", res.Status)
+	}
+}
+
+func TestEndpointListPagination(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`[{"endpointId":"e1"},{"endpointId":"e2"},{"endpointId":"e3"},{"endpointId":"e4"},{"endpointId":"e5"}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	page := func(url string) endpointPageResponse {
+		t.Helper()
+		res := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: url})
+		if res.Status != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", url, res.Status, res.Body)
+		}
+		var got endpointPageResponse
+		if err := json.Unmarshal(res.Body, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	got := page("endpoint-list?page=2&pageSize=2")
+	if got.Total != 5 || got.Page != 2 || got.PageSize != 2 || len(got.Items) != 2 || string(got.Items[0]) != `{"endpointId":"e3"}` {
+		t.Errorf("unexpected page %+v", got)
+	}
+	if got := page("endpoint-list?page=3&pageSize=2"); len(got.Items) != 1 || got.Total != 5 {
+		t.Errorf("expected short last page, got %+v", got)
+	}
+	if got := page("endpoint-list?page=9&pageSize=2"); len(got.Items) != 0 || got.Items == nil {
+		t.Errorf("expected empty page past the end, got %+v", got)
+	}
+	if calls != 1 {
+		t.Errorf("expected the list to be fetched once, got %d calls", calls)
+	}
+
+	if res := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list?page=0"}); res.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for page 0, got %d", res.Status)
+	}
+	// Without paging parameters the WEMS list is passed through unchanged.
+	if res := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"}); res.Body[0] != '[' {
+		t.Errorf("expected plain list, got %s", res.Body)
+	}
+}
+
+func TestConfigResourceOmitsSecret(t *testing.T) {
+	settings, err := loadSettings(backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"client_id":"id","base_url":"http://127.0.0.1:0","default_aggregate_function":"max","min_interval":"1m"}`),
+		DecryptedSecureJSONData: map[string]string{"client_secret": "s3cr3t-value"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := &Datasource{baseURL: settings.BaseURL, clientID: settings.ClientID, clientSecret: settings.ClientSecret, settings: settings}
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "config", URL: "config"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+	}
+	if strings.Contains(string(res.Body), "s3cr3t-value") {
+		t.Fatalf("config leaks the client secret: %s", res.Body)
+	}
+	var got editorConfig
+	if err := json.Unmarshal(res.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BaseURL != "http://127.0.0.1:0" || got.DefaultAggregateFunction != "max" || got.MinInterval != "1m" || got.QueryTimeoutSeconds != 20 {
+		t.Errorf("unexpected config %+v", got)
+	}
+}
+
+func TestConditionalResourceRequests(t *testing.T) {
+	var fetches, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`[{"endpointId":"ep1","friendlyName":"Plant A"}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ConditionalRequests = true
+
+	first := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"})
+	second := callResource(t, ds, &backend.CallResourceRequest{Path: "endpoint-list", URL: "endpoint-list"})
+	if first.Status != http.StatusOK || second.Status != http.StatusOK {
+		t.Fatalf("unexpected statuses %d, %d: %s", first.Status, second.Status, second.Body)
+	}
+	if fetches != 1 || notModified != 1 {
+		t.Fatalf("expected one fetch and one 304, got %d and %d", fetches, notModified)
+	}
+	if string(second.Body) != string(first.Body) || !strings.Contains(string(second.Body), "Plant A") {
+		t.Errorf("expected the cached payload on 304, got %s", second.Body)
+	}
+}
+
+func TestResolveAppliances(t *testing.T) {
+	var descriptions int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		descriptions++
+		if r.URL.Path != "/v1/endpoint/ep/description" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"processes":[{"id":"p1","name":"Heating","appliances":[{"id":"app1","friendlyName":"Boiler"},{"id":"app2"}]}],` +
+			`"appliances":[{"id":"app3","friendlyName":"Main meter"}]}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	for i := 0; i < 2; i++ {
+		res := callResource(t, ds, &backend.CallResourceRequest{
+			Path: "resolve-appliances",
+			URL:  "resolve-appliances?endpointId=ep&applianceIds=app1,app3,app2,missing",
+		})
+		if res.Status != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", res.Status, res.Body)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(res.Body, &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"app1": "Boiler", "app3": "Main meter", "app2": "app2", "missing": "missing"}
+		if len(got) != len(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		for id, name := range want {
+			if got[id] != name {
+				t.Errorf("%s: expected %q, got %q", id, name, got[id])
+			}
+		}
+	}
+	if descriptions != 1 {
+		t.Errorf("expected the description to be cached, got %d requests", descriptions)
+	}
+
+	res := callResource(t, ds, &backend.CallResourceRequest{Path: "resolve-appliances", URL: "resolve-appliances?endpointId=ep"})
+	if res.Status != http.StatusBadRequest {
+		t.Errorf("expected bad request without applianceIds, got %d", res.Status)
+	}
+}
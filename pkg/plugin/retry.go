@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultRetryBudget is the number of series request retries a QueryData call
+// may make in total when MaxRetries is set without RetryBudget.
+const defaultRetryBudget = 10
+
+// retryBackoff is the wait before the first retry of a series request; each
+// further retry waits one more retryBackoff.
+const retryBackoff = 100 * time.Millisecond
+
+// ErrRetryBudgetExhausted is returned for series requests of a QueryData call
+// whose retry budget ran out; they fail without contacting WEMS.
+var ErrRetryBudgetExhausted = errors.New("retry budget of the query batch exhausted, WEMS seems degraded")
+
+func (d *Datasource) retryBudget() int {
+	if d.settings.RetryBudget > 0 {
+		return d.settings.RetryBudget
+	}
+	return defaultRetryBudget
+}
+
+// retryBudget counts the retries left to the queries of one QueryData call.
+// Once a retry is denied the budget is exhausted, and the remaining requests
+// of the batch fail fast.
+type retryBudget struct {
+	mu        sync.Mutex
+	remaining int
+	exhausted bool
+}
+
+type retryBudgetKey struct{}
+
+// withRetryBudget returns a context whose series requests share a budget of n
+// retries.
+func withRetryBudget(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, &retryBudget{remaining: n})
+}
+
+// retryBudgetOf returns the retry budget of ctx, or nil if it has none.
+func retryBudgetOf(ctx context.Context) *retryBudget {
+	b, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	return b
+}
+
+// take consumes one retry, reporting false and marking the budget exhausted
+// if none is left. A nil budget allows every retry.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		b.exhausted = true
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// isExhausted reports whether a retry was denied by b.
+func (b *retryBudget) isExhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exhausted
+}
+
+// sendSeriesRequest sends a series request built by newReq with failover,
+// retrying connection failures and statuses whose action is StatusActionRetry
+// up to MaxRetries times as far as the retry budget of ctx allows.
+func (d *Datasource) sendSeriesRequest(ctx context.Context, client *http.Client, newReq func(baseURL string) (*http.Request, error)) (*http.Response, error) {
+	budget := retryBudgetOf(ctx)
+	if budget.isExhausted() {
+		return nil, ErrRetryBudgetExhausted
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := d.doWithFailover(client, newReq)
+		if ctx.Err() != nil || attempt >= d.settings.MaxRetries || (err == nil && d.statusAction(resp.StatusCode) != StatusActionRetry) {
+			return resp, err
+		}
+		if !budget.take() {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * retryBackoff):
+		}
+	}
+}
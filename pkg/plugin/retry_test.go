@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestRetryRecoversFromTransientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxRetries = 2
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestRetryBudgetSharedAcrossQueries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.MaxRetries = 3
+	ds.settings.RetryBudget = 2
+
+	var queries []backend.DataQuery
+	for _, refID := range []string{"A", "B", "C"} {
+		queries = append(queries, backend.DataQuery{
+			RefID: refID,
+			JSON:  []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp` + refID + `"}`),
+		})
+	}
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A makes its first request plus the two budgeted retries; B and C
+	// fail without a request.
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+	if res := resp.Responses["A"]; res.Error == nil || !strings.Contains(res.Error.Error(), "502") {
+		t.Errorf("expected A to fail with the WEMS error, got %v", res.Error)
+	}
+	for _, refID := range []string{"B", "C"} {
+		if res := resp.Responses[refID]; res.Error == nil || !strings.Contains(res.Error.Error(), ErrRetryBudgetExhausted.Error()) {
+			t.Errorf("expected %s to fail fast, got %v", refID, res.Error)
+		}
+	}
+
+	// The budget is per call, so the next refresh tries again.
+	calls.Store(0)
+	if _, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries[:1]}); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected a fresh budget, got %d requests", got)
+	}
+}
@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// searchResult is an entry of the search resource.
+type searchResult struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// search serves the search resource, returning the endpoints or the
+// appliances of an endpoint whose name contains q, ignoring case.
+func (d *Datasource) search(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	var query url.Values
+	if parsedUrl, err := url.Parse(req.URL); err == nil {
+		query = parsedUrl.Query()
+	}
+	q := strings.ToLower(query.Get("q"))
+
+	var candidates []searchResult
+	var errResp *backend.CallResourceResponse
+	switch query.Get("type") {
+	case "endpoint":
+		candidates, errResp = d.searchEndpoints(ctx)
+	case "appliance":
+		endpointId := query.Get("endpointId")
+		if endpointId == "" {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   []byte("Missing endpointId parameter"),
+			})
+		}
+		candidates, errResp = d.searchAppliances(ctx, endpointId)
+	default:
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadRequest,
+			Body:   []byte("Invalid type parameter, expected endpoint or appliance"),
+		})
+	}
+	if errResp != nil {
+		return sender.Send(errResp)
+	}
+
+	result := make([]searchResult, 0, len(candidates))
+	for _, c := range candidates {
+		if strings.Contains(strings.ToLower(c.Label), q) {
+			result = append(result, c)
+		}
+	}
+	return sendJSON(sender, result)
+}
+
+// searchEndpoints lists all endpoints, labelled with their friendly name.
+func (d *Datasource) searchEndpoints(ctx context.Context) ([]searchResult, *backend.CallResourceResponse) {
+	body, errResp := d.cachedResourceBody(ctx, d.currentBaseURL()+"/v1/endpoint/")
+	if errResp != nil {
+		return nil, errResp
+	}
+	var endpoints []struct {
+		EndpointID   string `json:"endpointId"`
+		FriendlyName string `json:"friendlyName"`
+	}
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return nil, &backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("Failed to parse endpoints: " + err.Error()),
+		}
+	}
+	result := make([]searchResult, 0, len(endpoints))
+	for _, ep := range endpoints {
+		label := ep.FriendlyName
+		if label == "" {
+			label = ep.EndpointID
+		}
+		result = append(result, searchResult{ID: ep.EndpointID, Label: label})
+	}
+	return result, nil
+}
+
+// searchAppliances lists the appliances of an endpoint, labelled with their
+// friendly name. The description is shared with the list prefetch through
+// cachedDescription. Unlike appliance-list, model names are not looked up.
+func (d *Datasource) searchAppliances(ctx context.Context, endpointId string) ([]searchResult, *backend.CallResourceResponse) {
+	desc, errResp := d.cachedDescription(ctx, endpointId)
+	if errResp != nil {
+		return nil, errResp
+	}
+	items, err := desc.applianceItems()
+	if err != nil {
+		return nil, &backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte("Failed to parse appliances: " + err.Error()),
+		}
+	}
+	result := make([]searchResult, 0, len(items))
+	for _, app := range items {
+		label := app.FriendlyName
+		if label == "" {
+			label = app.ID
+		}
+		result = append(result, searchResult{ID: app.ID, Label: label})
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSearch(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/v1/endpoint/":
+			_, _ = w.Write([]byte(`[{"endpointId":"ep1","friendlyName":"Plant North"},{"endpointId":"ep2","friendlyName":"Office"},{"endpointId":"northgate"}]`))
+		case "/v1/endpoint/ep1/description":
+			_, _ = w.Write([]byte(`{"processes":[{"name":"Main","appliances":[{"id":"a1","friendlyName":"Heat Pump"},{"id":"a2","friendlyName":"PV Inverter"}]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	search := func(url string) []searchResult {
+		t.Helper()
+		res := callResource(t, ds, &backend.CallResourceRequest{Path: "search", URL: url})
+		if res.Status != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", url, res.Status, res.Body)
+		}
+		var got []searchResult
+		if err := json.Unmarshal(res.Body, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	got := search("search?type=endpoint&q=NORTH")
+	if len(got) != 2 || got[0].ID != "ep1" || got[1].ID != "northgate" {
+		t.Errorf("unexpected endpoint matches %+v", got)
+	}
+	if got := search("search?type=endpoint&q=off"); len(got) != 1 || got[0].Label != "Office" {
+		t.Errorf("unexpected endpoint matches %+v", got)
+	}
+	if got := search("search?type=appliance&endpointId=ep1&q=pump"); len(got) != 1 || got[0].ID != "a1" {
+		t.Errorf("unexpected appliance matches %+v", got)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected lists to be fetched once each, got %d upstream calls", n)
+	}
+
+	for _, url := range []string{"search?type=process&q=x", "search?type=appliance&q=x"} {
+		if res := callResource(t, ds, &backend.CallResourceRequest{Path: "search", URL: url}); res.Status != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, res.Status)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// lastGoodTTL is how long the frames of a successful query are kept for
+// ServeStaleOnError.
+const lastGoodTTL = time.Hour
+
+// lastGood is a successful query result kept to be served when WEMS fails.
+// With CompressCachedFrames the frames are kept as gzipped Arrow in
+// compressed instead of in frames.
+type lastGood struct {
+	frames     []*data.Frame
+	compressed [][]byte
+	fetched    time.Time
+	tr         backend.TimeRange
+}
+
+// querySignature identifies a query model and the length of its time range,
+// so a dashboard refresh of a relative range finds the result of the
+// previous one while other ranges, such as a zoomed in one, do not.
+func querySignature(qm WEMSQueryModel, tr backend.TimeRange) string {
+	b, _ := json.Marshal(qm)
+	return fmt.Sprintf("%s|%d", b, tr.To.Sub(tr.From)/time.Second)
+}
+
+// rememberFrames stores copies of frames as the last good result of qm over
+// tr, so that later changes to frames do not reach the stored result.
+func (d *Datasource) rememberFrames(qm WEMSQueryModel, tr backend.TimeRange, frames []*data.Frame) {
+	entry := lastGood{fetched: time.Now(), tr: tr}
+	if d.settings.CompressCachedFrames {
+		// Frames Arrow cannot encode are kept uncompressed.
+		if compressed, err := compressFrames(frames); err == nil {
+			entry.compressed = compressed
+		}
+	}
+	if entry.compressed == nil {
+		entry.frames = copyFrames(frames)
+	}
+	d.lastGood.Set(querySignature(qm, tr), entry, lastGoodTTL)
+}
+
+// copyFrames returns deep copies of frames: their values, labels, field
+// configs and metadata can be changed without affecting frames.
+func copyFrames(frames []*data.Frame) []*data.Frame {
+	copies := make([]*data.Frame, 0, len(frames))
+	for _, f := range frames {
+		c := f.EmptyCopy()
+		for i, field := range f.Fields {
+			for row := 0; row < field.Len(); row++ {
+				c.Fields[i].Append(field.CopyAt(row))
+			}
+			if field.Config != nil {
+				config := *field.Config
+				c.Fields[i].Config = &config
+			}
+		}
+		if f.Meta != nil {
+			meta := *f.Meta
+			meta.Notices = slices.Clone(f.Meta.Notices)
+			c.SetMeta(&meta)
+		}
+		copies = append(copies, c)
+	}
+	return copies
+}
+
+// compressFrames serializes each frame to Arrow and gzips it.
+func compressFrames(frames []*data.Frame) ([][]byte, error) {
+	encoded, err := data.Frames(frames).MarshalArrow()
+	if err != nil {
+		return nil, err
+	}
+	compressed := make([][]byte, len(encoded))
+	for i, b := range encoded {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		compressed[i] = buf.Bytes()
+	}
+	return compressed, nil
+}
+
+// decompressFrames reverses compressFrames.
+func decompressFrames(compressed [][]byte) ([]*data.Frame, error) {
+	encoded := make([][]byte, len(compressed))
+	for i, b := range compressed {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if encoded[i], err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	return data.UnmarshalArrowFrames(encoded)
+}
+
+// staleFrames returns copies of the last good frames of qm over a range of
+// the length of tr with a warning that they are stale because of fetchErr.
+// Results of a range that does not overlap tr are not served.
+func (d *Datasource) staleFrames(qm WEMSQueryModel, tr backend.TimeRange, fetchErr error) ([]*data.Frame, bool) {
+	cached, ok := d.lastGood.Get(querySignature(qm, tr))
+	if !ok || cached.tr.To.Before(tr.From) || cached.tr.From.After(tr.To) {
+		return nil, false
+	}
+	frames := copyFrames(cached.frames)
+	if cached.compressed != nil {
+		var err error
+		if frames, err = decompressFrames(cached.compressed); err != nil {
+			return nil, false
+		}
+	}
+	notice := data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text: fmt.Sprintf("Showing stale data from %s: WEMS request failed: %s",
+			cached.fetched.UTC().Format(time.RFC3339), d.errorText(fetchErr)),
+	}
+	for _, f := range frames {
+		f.AppendNotices(notice)
+	}
+	return frames, true
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryServeStaleOnError(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":42}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ServeStaleOnError = true
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	if res := runQuery(ds, model); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	fail.Store(true)
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatalf("expected stale data instead of an error, got %v", res.Error)
+	}
+	frame := res.Frames[0]
+	if got := frame.Fields[1].At(0).(float64); got != 42 {
+		t.Errorf("expected cached value 42, got %v", got)
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "stale") {
+		t.Fatalf("expected stale notice, got %+v", frame.Meta)
+	}
+
+	// Serving stale data again must not pile up notices on the cached frames.
+	res = runQuery(ds, model)
+	if n := len(res.Frames[0].Meta.Notices); n != 1 {
+		t.Errorf("expected one notice, got %d", n)
+	}
+
+	// Without a previous result the error is returned.
+	other := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"other"}`
+	if res := runQuery(ds, other); res.Error == nil {
+		t.Error("expected error for a query without cached data")
+	}
+}
+
+func TestCompressedFramesRoundTrip(t *testing.T) {
+	value := data.NewField("value", data.Labels{"appliance_id": "app"}, []float64{1.5, 2, 230.25})
+	value.Config = &data.FieldConfig{Unit: "V", DisplayNameFromDS: "Voltage"}
+	frame := data.NewFrame("ep/app/svc/dp",
+		data.NewField("time", nil, []time.Time{time.Unix(1700000000, 0).UTC(), time.Unix(1700000060, 0).UTC(), time.Unix(1700000120, 0).UTC()}),
+		value,
+		data.NewField("interpolated", nil, []bool{false, true, false}),
+	)
+	frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "check me"})
+	other := data.NewFrame("raw", data.NewField("time", nil, []time.Time{}), data.NewField("value", nil, []string{}))
+
+	compressed, err := compressFrames([]*data.Frame{frame, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decompressFrames(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(got))
+	}
+	for i, want := range []*data.Frame{frame, other} {
+		wantJSON, _ := json.Marshal(want)
+		gotJSON, _ := json.Marshal(got[i])
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("frame %d changed:\nwant %s\ngot  %s", i, wantJSON, gotJSON)
+		}
+	}
+}
+
+func TestQueryServeStaleCompressed(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":42}]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.ServeStaleOnError = true
+	ds.settings.CompressCachedFrames = true
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	if res := runQuery(ds, model); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	fail.Store(true)
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatalf("expected stale data instead of an error, got %v", res.Error)
+	}
+	if got := res.Frames[0].Fields[1].At(0).(float64); got != 42 {
+		t.Errorf("expected cached value 42, got %v", got)
+	}
+}
+
+func TestStaleFramesAreCopiesPerRange(t *testing.T) {
+	ds := newTestDatasource("http://wems")
+	qm := WEMSQueryModel{EndpointID: "ep", ApplianceID: "app", ServiceURI: "svc", DataPoint: "dp"}
+	from := time.Unix(1700000000, 0)
+	tr := backend.TimeRange{From: from, To: from.Add(time.Hour)}
+	frame := data.NewFrame("ep/app/svc/dp",
+		data.NewField("time", nil, []time.Time{from}),
+		data.NewField("value", data.Labels{"appliance_id": "app"}, []float64{42}),
+	)
+	ds.rememberFrames(qm, tr, []*data.Frame{frame})
+
+	// Labeling the returned frames must not change the stored result.
+	frame.Fields[1].Labels["process"] = "p1"
+	frame.Fields[1].Set(0, 1.0)
+	stale, ok := ds.staleFrames(qm, tr, errors.New("down"))
+	if !ok {
+		t.Fatal("expected stale frames")
+	}
+	stale[0].Fields[1].Labels["process"] = "p2"
+	stale[0].Fields[1].Set(0, 2.0)
+	stale, _ = ds.staleFrames(qm, tr, errors.New("down"))
+	if got := stale[0].Fields[1].At(0).(float64); got != 42 {
+		t.Errorf("expected stored value 42, got %v", got)
+	}
+	if _, ok := stale[0].Fields[1].Labels["process"]; ok {
+		t.Errorf("expected stored labels to be unchanged, got %v", stale[0].Fields[1].Labels)
+	}
+
+	// A refresh of the range is served, other ranges are not.
+	for _, tc := range []struct {
+		name string
+		tr   backend.TimeRange
+		want bool
+	}{
+		{"shifted", backend.TimeRange{From: from.Add(time.Minute), To: from.Add(time.Hour + time.Minute)}, true},
+		{"zoomed", backend.TimeRange{From: from, To: from.Add(time.Minute)}, false},
+		{"disjoint", backend.TimeRange{From: from.Add(-2 * time.Hour), To: from.Add(-time.Hour)}, false},
+	} {
+		if _, ok := ds.staleFrames(qm, tc.tr, errors.New("down")); ok != tc.want {
+			t.Errorf("%s: expected served %v, got %v", tc.name, tc.want, ok)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Actions for upstream statuses of series requests, see
+// DatasourceSettings.StatusActions.
+const (
+	// StatusActionError fails the query.
+	StatusActionError = "error"
+	// StatusActionEmpty returns an empty series.
+	StatusActionEmpty = "empty"
+	// StatusActionRetry retries the request within MaxRetries and the retry
+	// budget, failing the query once they are used up.
+	StatusActionRetry = "retry"
+)
+
+// validateStatusActions returns an error if actions maps anything but an HTTP
+// status to a known action.
+func validateStatusActions(actions map[int]string) error {
+	for status, action := range actions {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid status_actions status %d", status)
+		}
+		switch action {
+		case StatusActionError, StatusActionEmpty, StatusActionRetry:
+		default:
+			return fmt.Errorf("unsupported status_actions action %q for status %d", action, status)
+		}
+	}
+	return nil
+}
+
+// statusAction returns what to do with a series request answered with
+// status: the action configured in StatusActions, else StatusActionEmpty for
+// TreatAsEmpty statuses, StatusActionRetry for 429 and 5xx and
+// StatusActionError for anything else.
+func (d *Datasource) statusAction(status int) string {
+	if action, ok := d.settings.StatusActions[status]; ok {
+		return action
+	}
+	switch {
+	case slices.Contains(d.settings.TreatAsEmpty, status):
+		return StatusActionEmpty
+	case status == http.StatusTooManyRequests || status >= 500:
+		return StatusActionRetry
+	default:
+		return StatusActionError
+	}
+}
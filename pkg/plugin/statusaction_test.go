@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestStatusActions(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 || status == http.StatusServiceUnavailable {
+			http.Error(w, "upstream", status)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+	}))
+	defer srv.Close()
+	model := `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`
+
+	settings, err := loadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"client_id":"id","base_url":"` + srv.URL + `","max_retries":2,"status_actions":{"503":"empty","404":"retry","500":"error"}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := newTestDatasource(srv.URL)
+	ds.settings = settings
+
+	res := runQuery(ds, model)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Rows() != 0 {
+		t.Fatalf("expected 503 to yield one empty frame, got %v", res.Frames)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 503 not to be retried, got %d requests", got)
+	}
+
+	calls.Store(0)
+	status = http.StatusNotFound
+	if res := runQuery(ds, model); res.Error != nil {
+		t.Fatalf("expected 404 to be retried, got %v", res.Error)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 requests for a retried 404, got %d", got)
+	}
+
+	calls.Store(0)
+	status = http.StatusInternalServerError
+	if res := runQuery(ds, model); res.Error == nil {
+		t.Fatal("expected 500 mapped to error to fail the query")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 500 mapped to error not to be retried, got %d requests", got)
+	}
+}
+
+func TestStatusActionsValidation(t *testing.T) {
+	for _, jsonData := range []string{
+		`{"client_id":"id","base_url":"http://127.0.0.1:0","status_actions":{"503":"ignore"}}`,
+		`{"client_id":"id","base_url":"http://127.0.0.1:0","status_actions":{"42":"empty"}}`,
+	} {
+		if _, err := loadSettings(backend.DataSourceInstanceSettings{JSONData: []byte(jsonData)}); err == nil {
+			t.Errorf("expected error for %s", jsonData)
+		}
+	}
+}
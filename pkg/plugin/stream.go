@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// streamPathPrefix prefixes the paths of live current-value streams. The
+// query model is passed as stream data.
+const streamPathPrefix = "current/"
+
+// streamPollInterval is how often a stream polls the current value.
+const streamPollInterval = 5 * time.Second
+
+// streamDrainTimeout is how long Dispose waits for running streams to exit.
+const streamDrainTimeout = 5 * time.Second
+
+// errStreamsClosed is returned when a stream starts after Dispose.
+var errStreamsClosed = errors.New("datasource is shutting down")
+
+// streamRegistry tracks the running streams of a datasource so Dispose can
+// stop them. The zero value is ready to use.
+type streamRegistry struct {
+	mu      sync.Mutex
+	closed  bool
+	nextID  int
+	running map[int]streamEntry
+	wg      sync.WaitGroup
+}
+
+type streamEntry struct {
+	path   string
+	cancel context.CancelFunc
+}
+
+// register adds a stream at path and returns a context cancelled on drain
+// and the function to call once the stream has exited.
+func (r *streamRegistry) register(ctx context.Context, path string) (context.Context, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, nil, errStreamsClosed
+	}
+	if r.running == nil {
+		r.running = make(map[int]streamEntry)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	id := r.nextID
+	r.nextID++
+	r.running[id] = streamEntry{path: path, cancel: cancel}
+	r.wg.Add(1)
+	return ctx, func() {
+		cancel()
+		r.mu.Lock()
+		delete(r.running, id)
+		r.mu.Unlock()
+		r.wg.Done()
+	}, nil
+}
+
+// drain stops all streams, refuses new ones and waits up to timeout for the
+// streams to exit. It returns the paths of the streams still running.
+func (r *streamRegistry) drain(timeout time.Duration) []string {
+	r.mu.Lock()
+	r.closed = true
+	for _, s := range r.running {
+		s.cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	paths := make([]string, 0, len(r.running))
+	for _, s := range r.running {
+		paths = append(paths, s.path)
+	}
+	return paths
+}
+
+// parseStreamQuery reads the query model of a current-value stream.
+func parseStreamQuery(path string, raw json.RawMessage) (WEMSQueryModel, error) {
+	var qm WEMSQueryModel
+	if !strings.HasPrefix(path, streamPathPrefix) {
+		return qm, errors.New("unknown stream path " + path)
+	}
+	if err := json.Unmarshal(raw, &qm); err != nil {
+		return qm, err
+	}
+	qm.Mode = QueryModeCurrent
+	return qm, validateQueryModel(&qm)
+}
+
+// SubscribeStream allows subscriptions to current-value streams with a valid
+// query model.
+func (d *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if _, err := parseStreamQuery(req.Path, req.Data); err != nil {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects publications; streams are read-only.
+func (d *Datasource) PublishStream(context.Context, *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream sends the current value of the streamed datapoint every
+// streamPollInterval until the stream is closed or the datasource disposed.
+// Failed polls are logged and retried on the next tick.
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	qm, err := parseStreamQuery(req.Path, req.Data)
+	if err != nil {
+		return err
+	}
+	ctx, done, err := d.streams.register(ctx, req.Path)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.sendCurrent(ctx, qm, sender); err != nil && ctx.Err() == nil {
+			log.DefaultLogger.Warn("Stream poll failed", "path", req.Path, "error", d.redact(err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Datasource) sendCurrent(ctx context.Context, qm WEMSQueryModel, sender *backend.StreamSender) error {
+	if err := d.getTokenIfNeeded(ctx); err != nil {
+		return err
+	}
+	if d.settings.PerEndpointTokens {
+		if endpointID := strings.TrimSpace(qm.EndpointID); endpointID != "" {
+			token, err := d.endpointToken(ctx, endpointID)
+			if err != nil {
+				return err
+			}
+			ctx = withBearer(ctx, token)
+		}
+	}
+	frame, err := d.currentFrame(ctx, qm)
+	if err != nil {
+		return err
+	}
+	return sender.SendFrame(frame, data.IncludeAll)
+}
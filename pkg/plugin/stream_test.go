@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+type packetChan chan *backend.StreamPacket
+
+func (c packetChan) Send(p *backend.StreamPacket) error {
+	c <- p
+	return nil
+}
+
+func TestStreamsStopOnDispose(t *testing.T) {
+	srv := seriesServer(t, `{"time":1700000000,"value":42}`)
+	ds := newTestDatasource(srv.URL)
+	req := &backend.RunStreamRequest{
+		Path: "current/power",
+		Data: []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power"}`),
+	}
+
+	packets := make(packetChan, 10)
+	exited := make(chan error, 2)
+	for range 2 {
+		go func() {
+			exited <- ds.RunStream(context.Background(), req, backend.NewStreamSender(packets))
+		}()
+	}
+	for range 2 {
+		select {
+		case <-packets:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the streams to send the current value")
+		}
+	}
+
+	ds.Dispose()
+	for range 2 {
+		select {
+		case err := <-exited:
+			if err != nil {
+				t.Errorf("expected a clean stop, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Dispose did not stop the stream")
+		}
+	}
+
+	if err := ds.RunStream(context.Background(), req, backend.NewStreamSender(packets)); !errors.Is(err, errStreamsClosed) {
+		t.Errorf("expected streams to be refused after Dispose, got %v", err)
+	}
+}
+
+func TestStreamRegistryReportsStuckStreams(t *testing.T) {
+	var r streamRegistry
+	_, _, err := r.register(context.Background(), "current/stuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, done, err := r.register(context.Background(), "current/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+
+	stuck := r.drain(50 * time.Millisecond)
+	if len(stuck) != 1 || stuck[0] != "current/stuck" {
+		t.Errorf("expected the stuck stream to be reported, got %v", stuck)
+	}
+}
+
+func TestSubscribeStream(t *testing.T) {
+	ds := newTestDatasource("http://127.0.0.1:0")
+	for _, tc := range []struct {
+		path, data string
+		want       backend.SubscribeStreamStatus
+	}{
+		{"current/power", `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power"}`, backend.SubscribeStreamStatusOK},
+		{"current/power", `{"endpoint_id":"ep"}`, backend.SubscribeStreamStatusNotFound},
+		{"series/power", `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power"}`, backend.SubscribeStreamStatusNotFound},
+	} {
+		res, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: tc.path, Data: []byte(tc.data)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != tc.want {
+			t.Errorf("%s %s: expected status %v, got %v", tc.path, tc.data, tc.want, res.Status)
+		}
+	}
+}
+
+func TestStreamUsesPerEndpointToken(t *testing.T) {
+	authorization := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/token" {
+			var req TokenRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			for ep := range req.Endpoints {
+				_, _ = w.Write([]byte("token-" + ep))
+				return
+			}
+			_, _ = w.Write([]byte("super"))
+			return
+		}
+		authorization <- r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"time":1700000000,"value":42}`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.PerEndpointTokens = true
+	defer ds.Dispose()
+
+	req := &backend.RunStreamRequest{
+		Path: "current/power",
+		Data: []byte(`{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power"}`),
+	}
+	packets := make(packetChan, 10)
+	go func() { _ = ds.RunStream(context.Background(), req, backend.NewStreamSender(packets)) }()
+	select {
+	case <-packets:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to send the current value")
+	}
+	if got := <-authorization; got != "Bearer token-ep" {
+		t.Errorf("expected the endpoint's scoped bearer, got %q", got)
+	}
+}
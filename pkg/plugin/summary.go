@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"math"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// summaryFrames returns the series frame for qm followed by a one-row frame
+// with statistics over the series.
+func (d *Datasource) summaryFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	points, notices, err := d.seriesPoints(ctx, qm, query)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := d.pointsFrame(qm, points)
+	if err != nil {
+		return nil, err
+	}
+	if len(notices) > 0 {
+		frame.AppendNotices(notices...)
+	}
+	summary, err := d.summaryFrame(frame.Name, d.unitFor(qm), points)
+	if err != nil {
+		return nil, err
+	}
+	return []*data.Frame{frame, summary}, nil
+}
+
+// summaryFrame computes min, max, avg, sum and last over the values of points.
+// Null, NaN and infinite values are skipped, unless OnNonFinite fails the
+// query; statistics of a series without values are null.
+// The frame is typed as numeric wide so it can be told apart from series
+// frames.
+func (d *Datasource) summaryFrame(name, unit string, points []TimeSeriesDataPoint) (*data.Frame, error) {
+	var present []TimeSeriesDataPoint
+	for _, p := range points {
+		if p.Value != nil {
+			present = append(present, p)
+		}
+	}
+	times, values, err := convertPoints(present, d.convertOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	var minV, maxV, avgV, sumV, lastV *float64
+	lo, hi, sum, last, n := math.Inf(1), math.Inf(-1), 0.0, -1, 0
+	for i, v := range values {
+		if isNonFinite(v) {
+			continue
+		}
+		lo, hi, sum, n = math.Min(lo, v), math.Max(hi, v), sum+v, n+1
+		if last < 0 || !times[i].Before(times[last]) {
+			last = i
+		}
+	}
+	if n > 0 {
+		avg := sum / float64(n)
+		minV, maxV, avgV, sumV, lastV = &lo, &hi, &avg, &sum, &values[last]
+	}
+
+	frame := data.NewFrame(name+" summary",
+		data.NewField("min", nil, []*float64{minV}),
+		data.NewField("max", nil, []*float64{maxV}),
+		data.NewField("avg", nil, []*float64{avgV}),
+		data.NewField("sum", nil, []*float64{sumV}),
+		data.NewField("last", nil, []*float64{lastV}),
+	)
+	if unit != "" {
+		for _, f := range frame.Fields {
+			f.Config = &data.FieldConfig{Unit: unit}
+		}
+	}
+	frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericWide})
+	return frame, nil
+}
+
+// splitSummaries separates summary frames from series frames, keeping the
+// order within each group.
+func splitSummaries(frames []*data.Frame) (series, summaries []*data.Frame) {
+	for _, f := range frames {
+		if f.Meta != nil && f.Meta.Type == data.FrameTypeNumericWide {
+			summaries = append(summaries, f)
+		} else {
+			series = append(series, f)
+		}
+	}
+	return series, summaries
+}
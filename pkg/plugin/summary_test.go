@@ -0,0 +1,47 @@
+package plugin
+
+import "testing"
+
+func TestQuerySummaryStats(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":4},{"time":1700000060,"value":null},{"time":1700000120,"value":"1"},{"time":1700000180,"value":7}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","summary_stats":true}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("expected series and summary frame, got %d frames", len(res.Frames))
+	}
+	summary := res.Frames[1]
+	for name, want := range map[string]float64{"min": 1, "max": 7, "avg": 4, "sum": 12, "last": 7} {
+		field, _ := summary.FieldByName(name)
+		if field == nil {
+			t.Fatalf("missing %s field", name)
+		}
+		if got := field.At(0).(*float64); got == nil || *got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+
+	// Summary frames are kept apart when series are joined wide.
+	ds.settings.FrameFormat = FrameFormatWide
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","summary_stats":true}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 || res.Frames[1].Name != summary.Name {
+		t.Fatalf("expected wide frame followed by summary, got %d frames", len(res.Frames))
+	}
+}
+
+func TestSummaryFrameEmpty(t *testing.T) {
+	ds := newTestDatasource("")
+	frame, err := ds.summaryFrame("s", "", []TimeSeriesDataPoint{{Time: 1, Value: nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := frame.Fields[0].At(0).(*float64); got != nil {
+		t.Errorf("expected null min for series without values, got %v", *got)
+	}
+}
@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/errgroup"
+)
+
+// multiTargetConcurrency caps the number of series fetched at the same time
+// for a multi-target or multi-appliance query.
+const multiTargetConcurrency = 4
+
+// targetFrames fetches every target of qm concurrently and returns one frame
+// per target, in target order, labelled with its service URI and datapoint.
+func (d *Datasource) targetFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	frames := make([]*data.Frame, len(qm.Targets))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(multiTargetConcurrency)
+	for i, target := range qm.Targets {
+		g.Go(func() error {
+			tqm := qm
+			tqm.Targets = nil
+			tqm.ServiceURI = target.ServiceURI
+			tqm.DataPoint = target.DataPoint
+			frame, err := d.seriesFrame(gctx, tqm, query)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", target.ServiceURI, target.DataPoint, err)
+			}
+			frame.Fields[1].Labels = data.Labels{
+				"service_uri": target.ServiceURI,
+				"data_point":  target.DataPoint,
+			}
+			frames[i] = frame
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// applianceFrames fetches the series of qm for every appliance in
+// qm.ApplianceIDs concurrently and returns one frame per appliance, in
+// order, labelled with its appliance ID. An appliance whose fetch fails
+// yields an empty frame, named and labelled like the others, carrying the
+// error as a notice; the query only fails if every appliance does.
+func (d *Datasource) applianceFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery) ([]*data.Frame, error) {
+	frames := make([]*data.Frame, len(qm.ApplianceIDs))
+	errs := make([]error, len(qm.ApplianceIDs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(multiTargetConcurrency)
+	for i, applianceID := range qm.ApplianceIDs {
+		g.Go(func() error {
+			aqm := qm
+			aqm.ApplianceIDs = nil
+			aqm.Targets = nil
+			aqm.ApplianceID = applianceID
+			frame, err := d.seriesFrame(gctx, aqm, query)
+			if err != nil {
+				errs[i] = err
+				name := d.seriesName(qm.EndpointID, applianceID, qm.ServiceURI, qm.DataPoint)
+				frame = data.NewFrame(name,
+					data.NewField("time", nil, []time.Time{}),
+					data.NewField(name, nil, []float64{}),
+				)
+				frame.AppendNotices(data.Notice{
+					Severity: data.NoticeSeverityError,
+					Text:     fmt.Sprintf("appliance %s: %s", applianceID, d.errorText(err)),
+				})
+			}
+			frame.Fields[1].Labels = data.Labels{"appliance_id": applianceID}
+			frames[i] = frame
+			return nil
+		})
+	}
+	_ = g.Wait()
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(errs) {
+		return nil, fmt.Errorf("all appliances failed: %w", errors.Join(errs...))
+	}
+	return frames, nil
+}
+
+// aggregateFunctions returns the functions of a comma-separated
+// AggregateFunction, skipping empty entries.
+func aggregateFunctions(qm WEMSQueryModel) []string {
+	var functions []string
+	for _, fn := range strings.Split(qm.AggregateFunction, ",") {
+		if fn = strings.TrimSpace(fn); fn != "" {
+			functions = append(functions, fn)
+		}
+	}
+	return functions
+}
+
+// aggregateFrames fetches the series of qm once per aggregate function
+// concurrently and returns one frame per function, in order, labelled with
+// the function.
+func (d *Datasource) aggregateFrames(ctx context.Context, qm WEMSQueryModel, query backend.DataQuery, functions []string) ([]*data.Frame, error) {
+	frames := make([]*data.Frame, len(functions))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(multiTargetConcurrency)
+	for i, fn := range functions {
+		g.Go(func() error {
+			fqm := qm
+			fqm.AggregateFunction = fn
+			frame, err := d.seriesFrame(gctx, fqm, query)
+			if err != nil {
+				return fmt.Errorf("%s: %w", fn, err)
+			}
+			frame.Fields[1].Labels = data.Labels{"aggregate_function": fn}
+			frames[i] = frame
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQueryMultipleTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/svc1/power"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":100}]`))
+		case strings.HasSuffix(r.URL.Path, "/svc2/voltage"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":230},{"time":1700000060,"value":231}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","targets":[
+		{"service_uri":"svc1","data_point":"power"},
+		{"service_uri":" svc2 ","data_point":"voltage"}
+	]}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("expected one frame per target, got %d", len(res.Frames))
+	}
+	for i, want := range []struct {
+		service, datapoint string
+		rows               int
+	}{{"svc1", "power", 1}, {"svc2", "voltage", 2}} {
+		frame := res.Frames[i]
+		labels := frame.Fields[1].Labels
+		if labels["service_uri"] != want.service || labels["data_point"] != want.datapoint {
+			t.Errorf("frame %d: unexpected labels %v", i, labels)
+		}
+		if frame.Name != fmt.Sprintf("ep/app/%s/%s", want.service, want.datapoint) {
+			t.Errorf("frame %d: unexpected name %s", i, frame.Name)
+		}
+		if frame.Rows() != want.rows {
+			t.Errorf("frame %d: expected %d rows, got %d", i, want.rows, frame.Rows())
+		}
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","targets":[{"service_uri":"svc1","data_point":""}]}`)
+	if res.Error == nil {
+		t.Error("expected a target without datapoint to be rejected")
+	}
+}
+
+func TestQueryMultipleAppliances(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/series/app1/"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+		case strings.Contains(r.URL.Path, "/series/app3/"):
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":3}]`))
+		default:
+			http.Error(w, "unknown appliance", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","service_uri":"svc","data_point":"dp","appliance_ids":["app1"," app2 ","app3"]}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(res.Frames))
+	}
+	for i, want := range []string{"app1", "app2", "app3"} {
+		if got := res.Frames[i].Fields[1].Labels["appliance_id"]; got != want {
+			t.Errorf("frame %d: expected appliance_id %s, got %s", i, want, got)
+		}
+	}
+	if got := res.Frames[2].Fields[1].At(0).(float64); got != 3 {
+		t.Errorf("expected 3 for app3, got %v", got)
+	}
+	failed := res.Frames[1]
+	if failed.Fields[1].Len() != 0 || failed.Meta == nil || len(failed.Meta.Notices) != 1 || !strings.Contains(failed.Meta.Notices[0].Text, "unknown appliance") {
+		t.Errorf("expected empty frame with error notice for app2, got %+v", failed.Meta)
+	}
+	if got, want := failed.Fields[1].Name, ds.seriesName("ep", "app2", "svc", "dp"); got != want {
+		t.Errorf("expected the failed frame's value field to be named %q, got %q", want, got)
+	}
+
+	if res := runQuery(ds, `{"endpoint_id":"ep","service_uri":"svc","data_point":"dp","appliance_ids":["x","y"]}`); res.Error == nil {
+		t.Error("expected error when every appliance fails")
+	}
+}
+
+func TestQuerySplitAggregates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("aggregateFunction") {
+		case "min":
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":1}]`))
+		case "max":
+			_, _ = w.Write([]byte(`[{"time":1700000000,"value":9}]`))
+		default:
+			http.Error(w, "unexpected aggregateFunction "+r.URL.Query().Get("aggregateFunction"), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp",
+		"aggregate_function":"min, max","split_aggregates":true}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("expected one frame per aggregate function, got %d", len(res.Frames))
+	}
+	for i, want := range []struct {
+		fn    string
+		value float64
+	}{{"min", 1}, {"max", 9}} {
+		field := res.Frames[i].Fields[1]
+		if field.Labels["aggregate_function"] != want.fn {
+			t.Errorf("frame %d: unexpected labels %v", i, field.Labels)
+		}
+		if v, _ := field.ConcreteAt(0); v != want.value {
+			t.Errorf("frame %d: expected %v, got %v", i, want.value, v)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Token request failures, wrapped into the errors returned by
+// getTokenIfNeeded and endpointToken.
+var (
+	// ErrTokenAuth means WEMS rejected the client credentials.
+	ErrTokenAuth = errors.New("WEMS rejected the client credentials")
+	// ErrTokenNetwork means the token endpoint could not be reached or the
+	// response could not be read.
+	ErrTokenNetwork = errors.New("WEMS token endpoint unreachable")
+	// ErrTokenBadResponse means the token endpoint answered with an
+	// unexpected status or without a token.
+	ErrTokenBadResponse = errors.New("unexpected WEMS token response")
+)
+
+// tokenStatusError returns the token error kind for an unsuccessful token
+// response status.
+func tokenStatusError(status int) error {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return ErrTokenAuth
+	default:
+		return ErrTokenBadResponse
+	}
+}
+
+// tokenErrorStatus returns the query response status for a token error.
+func tokenErrorStatus(err error) backend.Status {
+	switch {
+	case errors.Is(err, ErrTokenAuth):
+		return backend.StatusUnauthorized
+	case errors.Is(err, ErrTokenNetwork), errors.Is(err, ErrTokenBadResponse):
+		return backend.StatusBadGateway
+	default:
+		return backend.StatusInternal
+	}
+}
+
+// tokenHealthMessage describes a token error for the health check.
+func tokenHealthMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenAuth):
+		return "Authentication failed, check client ID and secret: " + err.Error()
+	case errors.Is(err, ErrTokenNetwork):
+		return "Cannot reach WEMS, check the base URL and network: " + err.Error()
+	default:
+		return "Token error: " + err.Error()
+	}
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTokenErrorKinds(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+		status  backend.Status
+	}{
+		{"unauthorized", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+		}, ErrTokenAuth, backend.StatusUnauthorized},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "oops", http.StatusInternalServerError)
+		}, ErrTokenBadResponse, backend.StatusBadGateway},
+		{"empty token", func(w http.ResponseWriter, r *http.Request) {}, ErrTokenBadResponse, backend.StatusBadGateway},
+		{"unreachable", nil, ErrTokenNetwork, backend.StatusBadGateway},
+	} {
+		baseURL := closed.URL
+		if tc.handler != nil {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+			baseURL = srv.URL
+		}
+
+		ds := &Datasource{baseURL: baseURL}
+		err := ds.getTokenIfNeeded(context.Background())
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+		for _, other := range []error{ErrTokenAuth, ErrTokenNetwork, ErrTokenBadResponse} {
+			if other != tc.want && errors.Is(err, other) {
+				t.Errorf("%s: error also matches %v", tc.name, other)
+			}
+		}
+
+		ds = &Datasource{baseURL: baseURL}
+		if res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`); res.Status != tc.status {
+			t.Errorf("%s: expected query status %v, got %v", tc.name, tc.status, res.Status)
+		}
+	}
+}
+
+func TestCheckHealthTokenAuthMessage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad credentials", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL}
+	ds.settings.ClientID, ds.settings.ClientSecret = "id", "secret"
+	ds.settings.BaseURL = srv.URL
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusError || !strings.HasPrefix(res.Message, "Authentication failed") {
+		t.Errorf("expected an authentication failure, got %+v", res)
+	}
+}
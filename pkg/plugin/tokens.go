@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// endpointTokenLifetime is how long a token scoped to one endpoint is used.
+const endpointTokenLifetime = 30 * time.Minute
+
+// scopedToken is a cached token for a single endpoint, or the failure of
+// its last request.
+type scopedToken struct {
+	token  string
+	expiry time.Time
+
+	err     error
+	retryAt time.Time
+}
+
+// valid reports whether t holds a token that does not expire within the
+// next minute.
+func (t scopedToken) valid() bool {
+	return t.token != "" && time.Now().Before(t.expiry.Add(-1*time.Minute))
+}
+
+type bearerKey struct{}
+
+// withBearer returns a context whose WEMS requests authenticate with token
+// instead of the datasource token.
+func withBearer(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, bearerKey{}, token)
+}
+
+// bearer returns the token to authenticate requests made for ctx.
+func (d *Datasource) bearer(ctx context.Context) string {
+	if token, ok := ctx.Value(bearerKey{}).(string); ok {
+		return token
+	}
+	return d.currentToken()
+}
+
+// currentToken returns the datasource token, which the token watchdog and
+// shared token requests replace concurrently.
+func (d *Datasource) currentToken() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.token
+}
+
+// endpointToken returns a token scoped to endpointID, requesting a new one
+// when there is none yet or it is about to expire. Each endpoint's token
+// expires independently. Concurrent callers for the same endpoint share one
+// token request, which runs detached from them like requestToken, and a
+// failure is returned without contacting WEMS until tokenFailureBackoff passed.
+func (d *Datasource) endpointToken(ctx context.Context, endpointID string) (string, error) {
+	d.endpointTokensMu.Lock()
+	t := d.endpointTokens[endpointID]
+	d.endpointTokensMu.Unlock()
+	if t.valid() {
+		return t.token, nil
+	}
+	if t.err != nil && time.Now().Before(t.retryAt) {
+		return "", t.err
+	}
+
+	ch := d.endpointTokenGroup.DoChan(endpointID, func() (interface{}, error) {
+		d.endpointTokensMu.Lock()
+		t := d.endpointTokens[endpointID]
+		d.endpointTokensMu.Unlock()
+		if t.valid() {
+			return t.token, nil
+		}
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRequestTimeout)
+		defer cancel()
+		token, err := d.fetchToken(fetchCtx, endpointID)
+		d.endpointTokensMu.Lock()
+		defer d.endpointTokensMu.Unlock()
+		if d.endpointTokens == nil {
+			d.endpointTokens = make(map[string]scopedToken)
+		}
+		if err != nil {
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				d.endpointTokens[endpointID] = scopedToken{err: err, retryAt: time.Now().Add(tokenFailureBackoff)}
+			}
+			return nil, err
+		}
+		d.endpointTokens[endpointID] = scopedToken{token: token, expiry: time.Now().Add(endpointTokenLifetime)}
+		return token, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
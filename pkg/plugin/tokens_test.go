@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerEndpointTokens(t *testing.T) {
+	var mu sync.Mutex
+	tokenRequests := map[string]int{}
+	auth := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/v1/token" {
+			var req TokenRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			for ep := range req.Endpoints {
+				if req.SuperToken {
+					t.Errorf("expected scoped token request for %s", ep)
+				}
+				tokenRequests[ep]++
+				_, _ = w.Write([]byte("token-" + ep))
+				return
+			}
+			_, _ = w.Write([]byte("super"))
+			return
+		}
+		ep := strings.Split(r.URL.Path, "/")[3]
+		auth[ep] = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+	ds.settings.PerEndpointTokens = true
+
+	query := func(ep string) {
+		t.Helper()
+		if res := runQuery(ds, `{"endpoint_id":"`+ep+`","appliance_id":"app","service_uri":"svc","data_point":"dp"}`); res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	}
+	query("ep1")
+	query("ep2")
+	query("ep1")
+	if auth["ep1"] != "Bearer token-ep1" || auth["ep2"] != "Bearer token-ep2" {
+		t.Errorf("expected per-endpoint bearers, got %v", auth)
+	}
+	if tokenRequests["ep1"] != 1 || tokenRequests["ep2"] != 1 {
+		t.Errorf("expected one token request per endpoint, got %v", tokenRequests)
+	}
+
+	// Expiring ep1's token only renews that one.
+	ds.endpointTokensMu.Lock()
+	ds.endpointTokens["ep1"] = scopedToken{token: "old", expiry: time.Now()}
+	ds.endpointTokensMu.Unlock()
+	query("ep1")
+	query("ep2")
+	if tokenRequests["ep1"] != 2 || tokenRequests["ep2"] != 1 {
+		t.Errorf("expected only ep1 to be renewed, got %v", tokenRequests)
+	}
+}
+
+func TestEndpointTokenSharedRequestAndBackoff(t *testing.T) {
+	var requests atomic.Int32
+	var fail atomic.Bool
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		if fail.Load() {
+			http.Error(w, "token service down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("token-ep"))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _ = ds.endpointToken(context.Background(), "ep")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected one shared token request, got %d", n)
+	}
+	for i, token := range tokens {
+		if token != "token-ep" {
+			t.Errorf("caller %d: expected token-ep, got %q", i, token)
+		}
+	}
+
+	// A failure is returned again without another request.
+	fail.Store(true)
+	if _, err := ds.endpointToken(context.Background(), "other"); err == nil {
+		t.Fatal("expected token error")
+	}
+	if _, err := ds.endpointToken(context.Background(), "other"); err == nil {
+		t.Fatal("expected cached token error")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected the failure to be cached, got %d requests", n)
+	}
+}
@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultDialTimeout and defaultKeepAlive match the dialer of
+// http.DefaultTransport.
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// defaultMaxIdleConnsPerHost replaces the net/http default of 2, which makes
+// the concurrent queries of a dashboard reopen connections to WEMS.
+const defaultMaxIdleConnsPerHost = 16
+
+// newTransport builds the HTTP transport shared by all WEMS requests of a
+// datasource, trusting the CA bundle in TLSCACertFile in addition to the
+// system roots and applying the connection reuse settings.
+func newTransport(s DatasourceSettings) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}
+	if s.KeepAliveSeconds != 0 {
+		dialer.KeepAlive = time.Duration(s.KeepAliveSeconds) * time.Second
+	}
+	transport.DialContext = dialer.DialContext
+	if s.IdleConnTimeoutSeconds > 0 {
+		transport.IdleConnTimeout = time.Duration(s.IdleConnTimeoutSeconds) * time.Second
+	}
+	transport.DisableKeepAlives = s.DisableKeepAlives
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if s.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = s.MaxConnsPerHost
+	}
+	if s.TLSCACertFile != "" {
+		pem, err := os.ReadFile(s.TLSCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls_ca_cert_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca_cert_file %s contains no PEM certificates", s.TLSCACertFile)
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return transport, nil
+}
+
+// defaultQueryTimeout bounds the WEMS requests of a query without
+// TimeoutSeconds.
+const defaultQueryTimeout = 20 * time.Second
+
+type queryTimeoutKey struct{}
+
+// withQueryTimeout returns a context whose deadline and query requests use
+// timeout instead of defaultQueryTimeout.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithValue(ctx, queryTimeoutKey{}, timeout), timeout)
+}
+
+// queryTimeout returns the timeout for the query requests made with ctx.
+func queryTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return defaultQueryTimeout
+}
+
+// httpClient returns a client using the datasource transport with the given
+// timeout. Without a configured transport the default one is used.
+func (d *Datasource) httpClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if d.transport != nil {
+		client.Transport = d.transport
+	}
+	return client
+}
+
+// gzipBody compresses a request body for sending with Content-Encoding: gzip.
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTLSCACertFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("token"))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	newDS := func(jsonData string) (*Datasource, error) {
+		inst, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{JSONData: []byte(jsonData)})
+		if err != nil {
+			return nil, err
+		}
+		return inst.(*Datasource), nil
+	}
+
+	if _, err := newDS(`{"base_url":"` + srv.URL + `"}`); err == nil {
+		t.Fatal("expected untrusted certificate to be rejected without the CA file")
+	}
+	ds, err := newDS(`{"base_url":"` + srv.URL + `","tls_ca_cert_file":"` + caFile + `"}`)
+	if err != nil {
+		t.Fatalf("expected CA file to be trusted, got %v", err)
+	}
+	if ds.currentToken() != "token" {
+		t.Errorf("expected token, got %q", ds.currentToken())
+	}
+
+	_, err = newDS(`{"base_url":"` + srv.URL + `","tls_ca_cert_file":"` + filepath.Join(t.TempDir(), "missing.pem") + `"}`)
+	if err == nil || !strings.Contains(err.Error(), "tls_ca_cert_file") {
+		t.Errorf("expected read error naming the setting, got %v", err)
+	}
+}
+
+func TestTransportConnectionSettings(t *testing.T) {
+	transport, err := newTransport(DatasourceSettings{IdleConnTimeoutSeconds: 15, DisableKeepAlives: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.IdleConnTimeout != 15*time.Second || !transport.DisableKeepAlives {
+		t.Errorf("unexpected transport settings: idle %s, keep-alives disabled %v", transport.IdleConnTimeout, transport.DisableKeepAlives)
+	}
+
+	transport, err = newTransport(DatasourceSettings{MaxIdleConnsPerHost: 32, MaxConnsPerHost: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConnsPerHost != 32 || transport.MaxConnsPerHost != 64 {
+		t.Errorf("unexpected pool settings: %d idle, %d max per host", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+
+	transport, err = newTransport(DatasourceSettings{KeepAliveSeconds: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.IdleConnTimeout != http.DefaultTransport.(*http.Transport).IdleConnTimeout || transport.DisableKeepAlives {
+		t.Errorf("expected default connection reuse, got idle %s", transport.IdleConnTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost || transport.MaxConnsPerHost != 0 {
+		t.Errorf("expected default pool settings, got %d idle, %d max per host", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected transport without keep-alive probes to connect, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestQueryTimeoutSeconds(t *testing.T) {
+	if got := queryTimeout(context.Background()); got != defaultQueryTimeout {
+		t.Errorf("expected default timeout %s, got %s", defaultQueryTimeout, got)
+	}
+	ctx, cancel := withQueryTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute || queryTimeout(ctx) != time.Minute {
+		t.Errorf("expected a one minute timeout, got %s and deadline %v", queryTimeout(ctx), deadline)
+	}
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	defer close(release)
+	ds := newTestDatasource(srv.URL)
+
+	start := time.Now()
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","timeout_seconds":1}`)
+	if res.Error == nil {
+		t.Fatal("expected the query to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the query to give up after 1s, took %s", elapsed)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp","timeout_seconds":-5}`)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "timeout_seconds") {
+		t.Errorf("expected a negative timeout to be rejected, got %v", res.Error)
+	}
+}
+
+func TestCompressRequests(t *testing.T) {
+	var encoding string
+	var got TokenRequest
+	var decodeErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		var body io.Reader = r.Body
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				decodeErr = err
+				return
+			}
+			body = zr
+		}
+		got = TokenRequest{}
+		decodeErr = json.NewDecoder(body).Decode(&got)
+		_, _ = w.Write([]byte("token"))
+	}))
+	defer srv.Close()
+
+	ds := &Datasource{baseURL: srv.URL, clientID: "id", clientSecret: "secret"}
+	if _, err := ds.fetchToken(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "" || decodeErr != nil || got.ClientID != "id" {
+		t.Fatalf("expected a plain body by default, got encoding %q, %+v, %v", encoding, got, decodeErr)
+	}
+
+	ds.settings.CompressRequests = true
+	if _, err := ds.fetchToken(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "gzip" {
+		t.Fatalf("expected Content-Encoding gzip, got %q", encoding)
+	}
+	if decodeErr != nil || got.ClientID != "id" || got.ClientSecret != "secret" || !got.SuperToken {
+		t.Errorf("expected a decodable token request, got %+v, %v", got, decodeErr)
+	}
+}
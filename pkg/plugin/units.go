@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"strings"
+)
+
+// unitSuffixes maps lower-cased name suffixes to Grafana unit IDs for
+// InferUnits. Suffixes that are ambiguous once lower-cased, such as mW and
+// MW, are left out, as are single letters, see quantitySuffixes.
+var unitSuffixes = map[string]string{
+	"kw":      "kwatt",
+	"wh":      "watth",
+	"kwh":     "kwatth",
+	"va":      "voltamp",
+	"var":     "voltampreact",
+	"volt":    "volt",
+	"amp":     "amp",
+	"hz":      "hertz",
+	"degc":    "celsius",
+	"celsius": "celsius",
+	"degf":    "fahrenheit",
+	"kelvin":  "kelvin",
+	"pct":     "percent",
+	"percent": "percent",
+	"rh":      "humidity",
+	"bar":     "pressurebar",
+	"rpm":     "rotrpm",
+}
+
+// quantitySuffixes maps lower-cased single-letter suffixes to their Grafana
+// unit ID and the quantity word that must precede them, as in current_A.
+// Alone they are too common at the end of ordinary names, such as
+// voltage_phase_a.
+var quantitySuffixes = map[string]struct{ quantity, unit string }{
+	"w": {"power", "watt"},
+	"v": {"voltage", "volt"},
+	"a": {"current", "amp"},
+}
+
+// inferUnit guesses the Grafana unit of a series from the last word of its
+// data point name, or of its service URI, as in power_kW or temp-degC. It
+// returns an empty unit if neither ends in a known suffix.
+func inferUnit(dataPoint, serviceURI string) string {
+	for _, name := range []string{dataPoint, serviceURI} {
+		words := strings.FieldsFunc(name, func(r rune) bool {
+			return r == '_' || r == '-' || r == '.' || r == '/' || r == ' '
+		})
+		if len(words) < 2 {
+			continue
+		}
+		last := strings.ToLower(words[len(words)-1])
+		if unit, ok := unitSuffixes[last]; ok {
+			return unit
+		}
+		if q, ok := quantitySuffixes[last]; ok && strings.ToLower(words[len(words)-2]) == q.quantity {
+			return q.unit
+		}
+	}
+	return ""
+}
+
+// unitFor returns the unit of the series of qm: the explicit Unit, or one
+// inferred from its names if InferUnits is set.
+func (d *Datasource) unitFor(qm WEMSQueryModel) string {
+	if qm.Unit == "" && d.settings.InferUnits {
+		return inferUnit(qm.DataPoint, qm.ServiceURI)
+	}
+	return qm.Unit
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestInferUnit(t *testing.T) {
+	for _, tc := range []struct {
+		dataPoint, serviceURI, want string
+	}{
+		{"power_kW", "meter", "kwatt"},
+		{"temp_degC", "climate", "celsius"},
+		{"energy-kWh", "meter", "kwatth"},
+		{"Phase1.Voltage.V", "meter", "volt"},
+		{"humidity_RH", "climate", "humidity"},
+		{"value", "pressure_bar", "pressurebar"},
+		{"power_kW", "pressure_bar", "kwatt"},
+		{"kW", "meter", ""},
+		{"state", "switch", ""},
+		{"current_A", "meter", "amp"},
+		{"power_W", "meter", "watt"},
+		{"voltage_phase_a", "meter", ""},
+		{"flow", "pump", ""},
+		{"pump_flow_w", "pump", ""},
+		{"phase_v", "meter", ""},
+	} {
+		if got := inferUnit(tc.dataPoint, tc.serviceURI); got != tc.want {
+			t.Errorf("%s/%s: expected %q, got %q", tc.serviceURI, tc.dataPoint, tc.want, got)
+		}
+	}
+}
+
+func TestQueryInferUnits(t *testing.T) {
+	srv := seriesServer(t, `[{"time":1700000000,"value":1}]`)
+	ds := newTestDatasource(srv.URL)
+
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power_kW"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if cfg := res.Frames[0].Fields[1].Config; cfg != nil && cfg.Unit != "" {
+		t.Errorf("expected no unit unless enabled, got %q", cfg.Unit)
+	}
+
+	ds.settings.InferUnits = true
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power_kW"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if cfg := res.Frames[0].Fields[1].Config; cfg == nil || cfg.Unit != "kwatt" {
+		t.Errorf("expected inferred unit kwatt, got %+v", cfg)
+	}
+
+	res = runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"power_kW","unit":"watt"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if cfg := res.Frames[0].Fields[1].Config; cfg == nil || cfg.Unit != "watt" {
+		t.Errorf("expected explicit unit to win, got %+v", cfg)
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// validateSettings reports settings that cannot work: missing credentials or
+// base URLs that are not absolute http(s) URLs.
+func validateSettings(s DatasourceSettings) error {
+	if s.ClientID == "" || s.ClientSecret == "" {
+		return errors.New("client ID and client secret are required")
+	}
+	for _, raw := range append([]string{s.BaseURL}, s.BaseURLs...) {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid base URL %q: expected an absolute http or https URL", raw)
+		}
+	}
+	return nil
+}
+
+// AdmissionHandler rejects datasource settings that fail validateSettings
+// before they are saved, instead of failing only on the health check.
+type AdmissionHandler struct{}
+
+var _ backend.AdmissionHandler = AdmissionHandler{}
+
+// ValidateAdmission validates the settings carried in the plugin context of
+// req. Requests without settings are allowed.
+func (AdmissionHandler) ValidateAdmission(_ context.Context, req *backend.AdmissionRequest) (*backend.ValidationResponse, error) {
+	if err := validateAdmission(req); err != nil {
+		return &backend.ValidationResponse{
+			Allowed: false,
+			Result: &backend.StatusResult{
+				Status:  "Failure",
+				Message: err.Error(),
+				Reason:  "BadRequest",
+				Code:    http.StatusBadRequest,
+			},
+		}, nil
+	}
+	return &backend.ValidationResponse{Allowed: true}, nil
+}
+
+// MutateAdmission applies the same checks as ValidateAdmission and leaves the
+// object unchanged.
+func (AdmissionHandler) MutateAdmission(_ context.Context, req *backend.AdmissionRequest) (*backend.MutationResponse, error) {
+	if err := validateAdmission(req); err != nil {
+		return &backend.MutationResponse{
+			Allowed: false,
+			Result: &backend.StatusResult{
+				Status:  "Failure",
+				Message: err.Error(),
+				Reason:  "BadRequest",
+				Code:    http.StatusBadRequest,
+			},
+		}, nil
+	}
+	return &backend.MutationResponse{Allowed: true, ObjectBytes: req.ObjectBytes}, nil
+}
+
+func validateAdmission(req *backend.AdmissionRequest) error {
+	if req.Operation == backend.AdmissionRequestDelete || req.PluginContext.DataSourceInstanceSettings == nil {
+		return nil
+	}
+	settings, err := loadSettings(*req.PluginContext.DataSourceInstanceSettings)
+	if err != nil {
+		return err
+	}
+	return validateSettings(settings)
+}
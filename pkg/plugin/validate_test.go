@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func admissionRequest(jsonData string) *backend.AdmissionRequest {
+	return &backend.AdmissionRequest{
+		Operation: backend.AdmissionRequestCreate,
+		PluginContext: backend.PluginContext{
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{
+				JSONData:                []byte(jsonData),
+				DecryptedSecureJSONData: map[string]string{"client_secret": "secret"},
+			},
+		},
+	}
+}
+
+func TestValidateAdmission(t *testing.T) {
+	for _, tc := range []struct {
+		jsonData string
+		allowed  bool
+	}{
+		{`{"client_id":"id"}`, true},
+		{`{"client_id":"id","base_url":"https://wems.example.com/api/"}`, true},
+		{`{"client_id":"id","base_url":"wems.example.com"}`, false},
+		{`{"client_id":"id","base_urls":["https://a.example.com","ftp://b.example.com"]}`, false},
+		{`{"base_url":"https://wems.example.com"}`, false},
+		{`{"client_id":"id","auth_mode":"digest"}`, false},
+	} {
+		resp, err := AdmissionHandler{}.ValidateAdmission(context.Background(), admissionRequest(tc.jsonData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Allowed != tc.allowed {
+			t.Errorf("%s: expected allowed=%v, got %+v", tc.jsonData, tc.allowed, resp.Result)
+		}
+	}
+}
+
+func TestCheckHealthValidatesSettings(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL}
+	ds.settings.BaseURL = "not a url"
+	ds.settings.ClientID, ds.settings.ClientSecret = "id", "secret"
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusError || calls != 0 {
+		t.Errorf("expected validation failure without contacting WEMS, got %+v after %d calls", res, calls)
+	}
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// verboseBodyLimit is how much of a response body verbose logging records.
+const verboseBodyLimit = 2048
+
+const redacted = "[REDACTED]"
+
+var bearerRegexp = regexp.MustCompile(`(?i)bearer\s+\S+`)
+
+// redact replaces the client secret, the current token and any bearer
+// credentials in s.
+func (d *Datasource) redact(s string) string {
+	s = bearerRegexp.ReplaceAllString(s, "Bearer "+redacted)
+	for _, secret := range []string{d.clientSecret, d.currentToken()} {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// logVerbose logs the full exchange of req and resp at debug level, with
+// credentials redacted and the response body truncated to verboseBodyLimit.
+// The part of the body it reads is put back, so resp can be consumed as
+// usual afterwards. Token responses are never logged since their body is
+// the token itself.
+func (d *Datasource) logVerbose(id string, req *http.Request, resp *http.Response) {
+	headers := make(map[string]string, len(req.Header))
+	for k, v := range req.Header {
+		if k == "Authorization" {
+			headers[k] = redacted
+			continue
+		}
+		headers[k] = d.redact(strings.Join(v, ", "))
+	}
+
+	body := redacted
+	if !strings.HasSuffix(req.URL.Path, "/v1/token") {
+		head, _ := io.ReadAll(io.LimitReader(resp.Body, verboseBodyLimit))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+		body = d.redact(string(head))
+	}
+
+	log.DefaultLogger.Debug("WEMS exchange",
+		"requestId", id,
+		"method", req.Method,
+		"url", d.redact(req.URL.String()),
+		"headers", headers,
+		"status", resp.StatusCode,
+		"body", body,
+	)
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// recordingLogger keeps every message and its arguments as one line.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{})      { l.record(msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...interface{})       { l.record(msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})       { l.record(msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...interface{})      { l.record(msg, args...) }
+func (l *recordingLogger) With(args ...interface{}) log.Logger        { return l }
+func (l *recordingLogger) Level() log.Level                           { return log.Debug }
+func (l *recordingLogger) FromContext(ctx context.Context) log.Logger { return l }
+
+func TestVerboseLoggingRedacts(t *testing.T) {
+	logger := &recordingLogger{}
+	orig := log.DefaultLogger
+	log.DefaultLogger = logger
+	defer func() { log.DefaultLogger = orig }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/token" {
+			_, _ = w.Write([]byte("secret-token"))
+			return
+		}
+		// Echo the credentials back to make sure bodies are redacted too.
+		_, _ = w.Write([]byte(`[{"time":1700000000,"value":1,"note":"` + r.Header.Get("Authorization") + ` s3cr3t"}]`))
+	}))
+	defer srv.Close()
+	ds := &Datasource{baseURL: srv.URL, clientID: "id", clientSecret: "s3cr3t"}
+	ds.settings.VerboseLogging = true
+
+	if err := ds.getTokenIfNeeded(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	res := runQuery(ds, `{"endpoint_id":"ep","appliance_id":"app","service_uri":"svc","data_point":"dp"}`)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if got := res.Frames[0].Fields[1].Len(); got != 1 {
+		t.Fatalf("expected body to remain readable after logging, got %d points", got)
+	}
+
+	all := strings.Join(logger.lines, "\n")
+	if !strings.Contains(all, "WEMS exchange") || !strings.Contains(all, "/series/app/svc/dp") {
+		t.Fatalf("expected verbose exchange log, got:\n%s", all)
+	}
+	for _, secret := range []string{"secret-token", "s3cr3t"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("log contains %q:\n%s", secret, all)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	// tokenWatchdogLead is how long before expiry the watchdog refreshes the
+	// token. It is larger than the 1 minute buffer used by getTokenIfNeeded so
+	// requests never have to wait for a refresh.
+	tokenWatchdogLead = 2 * time.Minute
+
+	tokenWatchdogMinBackoff = time.Second
+	tokenWatchdogMaxBackoff = time.Minute
+)
+
+// startTokenWatchdog starts a goroutine that refreshes the token ahead of its
+// expiry. It is stopped by Dispose.
+func (d *Datasource) startTokenWatchdog() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.watchdogCancel = cancel
+	d.watchdogDone = make(chan struct{})
+	go d.runTokenWatchdog(ctx)
+}
+
+func (d *Datasource) runTokenWatchdog(ctx context.Context) {
+	defer close(d.watchdogDone)
+	backoff := tokenWatchdogMinBackoff
+	for {
+		d.mutex.Lock()
+		wait := time.Until(d.tokenExpiry) - tokenWatchdogLead
+		d.mutex.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := d.requestToken(ctx, true)
+		if err == nil {
+			backoff = tokenWatchdogMinBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.DefaultLogger.Warn("Token watchdog refresh failed", "error", d.redact(err.Error()), "retryIn", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, tokenWatchdogMaxBackoff)
+	}
+}
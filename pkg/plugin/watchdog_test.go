@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenWatchdogRefreshesBeforeExpiry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte("fresh-token"))
+	}))
+	defer srv.Close()
+
+	ds := &Datasource{
+		baseURL: srv.URL,
+		token:   "old-token",
+		// Due for a proactive refresh shortly, but still valid for requests.
+		tokenExpiry: time.Now().Add(tokenWatchdogLead + 50*time.Millisecond),
+	}
+	ds.startTokenWatchdog()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one proactive refresh, got %d", calls.Load())
+	}
+	// The refresh is stored by the shared token request, which may finish
+	// after the watchdog saw the response.
+	for time.Now().Before(deadline) {
+		if ds.currentToken() == "fresh-token" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		ds.Dispose()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Dispose did not stop the watchdog")
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if ds.token != "fresh-token" {
+		t.Errorf("expected refreshed token, got %q", ds.token)
+	}
+	if time.Until(ds.tokenExpiry) < 10*time.Minute {
+		t.Errorf("expected expiry to be extended, got %v", ds.tokenExpiry)
+	}
+}
+
+func TestTokenWatchdogRetriesAfterFailure(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("fresh-token"))
+	}))
+	defer srv.Close()
+
+	ds := &Datasource{baseURL: srv.URL, token: "old-token", tokenExpiry: time.Now()}
+	ds.startTokenWatchdog()
+	defer ds.Dispose()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() < 2 {
+		t.Fatalf("expected a retry after failure, got %d calls", calls.Load())
+	}
+}
+
+func TestTokenReadsDuringRefresh(t *testing.T) {
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf("token-%d", n.Add(1))))
+	}))
+	defer srv.Close()
+	ds := newTestDatasource(srv.URL)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20 {
+			_ = ds.requestToken(context.Background(), true)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if ds.bearer(context.Background()) == "" {
+				t.Error("expected a token after the refreshes")
+			}
+			return
+		default:
+			_ = ds.bearer(context.Background())
+			_ = ds.redact("Authorization failed")
+		}
+	}
+}
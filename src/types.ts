@@ -10,6 +10,37 @@ export interface MyQuery extends DataQuery {
   create_empty_values?: boolean;
   unit?: string;
   validValues?: string[];
+  precision?: number;
+  display_decimals?: number;
+  dedupe_timestamps?: boolean;
+  dedupe_keep?: 'first' | 'last';
+  scopedVars?: Record<string, { text: string; value: string }>;
+  stale_after_seconds?: number;
+  multi_resolution?: boolean;
+  detail_seconds?: number;
+  display_name?: string;
+  color?: string;
+  mode?: 'current' | 'raw';
+  targets?: Array<{ service_uri: string; data_point: string }>;
+  resample_step?: string;
+  fill_mode?: 'none' | 'forward' | 'zero';
+  bucket_reducer?: 'first' | 'last' | 'max' | 'min' | 'mean' | 'sum';
+  calendar_bucket?: 'day' | 'week' | 'month';
+  calendar_timezone?: string;
+  join_tolerance?: string;
+  clamp_min?: number;
+  clamp_max?: number;
+  clamp_mode?: 'drop' | 'clamp';
+  histogram?: boolean;
+  buckets?: number;
+  summary_stats?: boolean;
+  appliance_ids?: string[];
+  lttb?: boolean;
+  split_aggregates?: boolean;
+  numerator?: { service_uri: string; data_point: string };
+  denominator?: { service_uri: string; data_point: string };
+  timeout_seconds?: number;
+  endpoint_ids?: string[];
 }
 
 export const DEFAULT_QUERY: Partial<MyQuery> = {};
@@ -29,6 +60,56 @@ export interface DataSourceResponse {
 export interface MyDataSourceOptions extends DataSourceJsonData {
   client_id?: string;
   base_url?: string;
+  client_secret_key?: string;
+  enable_token_watchdog?: boolean;
+  base_urls?: string | string[];
+  treat_as_empty?: number[];
+  decimal_separator?: string;
+  group_separator?: string;
+  default_limit?: number;
+  auth_mode?: 'json_body' | 'basic';
+  on_mixed_type?: 'coerce' | 'error' | 'string';
+  frame_format?: 'long' | 'wide';
+  series_accept?: string;
+  max_response_bytes?: number;
+  max_points?: number;
+  on_max_points?: 'truncate' | 'error';
+  timezone?: string;
+  bool_mapping?: { true_value?: number; false_value?: number };
+  verbose_logging?: boolean;
+  soft_errors?: boolean;
+  model_lookup_concurrency?: number;
+  metadata_lookup_concurrency?: number;
+  serve_stale_on_error?: boolean;
+  warn_on_all_zero_coerced?: boolean;
+  tls_ca_cert_file?: string;
+  min_interval?: string;
+  per_endpoint_tokens?: boolean;
+  health_check_timeout_seconds?: number;
+  enable_list_prefetch?: boolean;
+  list_prefetch_interval_seconds?: number;
+  idle_conn_timeout_seconds?: number;
+  keep_alive_seconds?: number;
+  disable_keep_alives?: boolean;
+  compress_cached_frames?: boolean;
+  default_aggregate_function?: string;
+  sanitize_names?: boolean;
+  max_idle_conns_per_host?: number;
+  max_conns_per_host?: number;
+  on_non_finite?: 'null' | 'error';
+  error_verbosity?: 'full' | 'minimal';
+  max_retries?: number;
+  retry_budget?: number;
+  process_labels?: boolean;
+  query_delay_seconds?: number;
+  conditional_requests?: boolean;
+  retry_truncated?: boolean;
+  infer_units?: boolean;
+  compress_requests?: boolean;
+  limit_notice?: boolean;
+  model_labels?: boolean;
+  status_actions?: Record<string, 'error' | 'empty' | 'retry'>;
+  health_check_soft_fail?: boolean;
 }
 
 /**